@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	f "github.com/rprtr258/go-flow/fun"
+	r "github.com/rprtr258/go-flow/result"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/output"
+	"github.com/rprtr258/vk-utils/pkg/resolve"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	_commentsPostURL string
+	_commentsFormat  string
+	commentsCmd      = &cli.Command{
+		Name: "comments",
+		Usage: `Dump comment tree of post.
+Example:
+	vkutils comments -u https://vk.com/wall-2158488_651604 -f markdown
+`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "url",
+				Aliases:     []string{"u"},
+				Required:    true,
+				Usage:       "link or id of vk post",
+				Destination: &_commentsPostURL,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Aliases:     []string{"f"},
+				Value:       "tree",
+				Usage:       "format of text output: tree or markdown, other formats are chosen with --output",
+				Destination: &_commentsFormat,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.IsSet("format") && outputFormat != output.FormatText {
+				return fmt.Errorf("--format can't be used with --output %s", outputFormat)
+			}
+			var printer commentPrinter
+			switch _commentsFormat {
+			case "tree":
+				printer = &treePrinter{}
+			case "markdown":
+				printer = &markdownPrinter{}
+			default:
+				return fmt.Errorf("unknown comments format %q, expected tree or markdown, use --output for other formats", _commentsFormat)
+			}
+			comments := r.Map(resolve.Post(ctx.Context, client, _commentsPostURL), func(postID vk.PostID) vk.ErrStream[vk.Comment] {
+				return client.Comments(ctx.Context, postID)
+			})
+			return r.Fold(
+				comments,
+				func(comments vk.ErrStream[vk.Comment]) error {
+					enc, err := newCommentEncoder(printer)
+					if err != nil {
+						return err
+					}
+					if err := encodeAll[vk.Comment](comments, enc); err != nil {
+						return err
+					}
+					if err := ctxErr(ctx); err != nil {
+						return err
+					}
+					if err := comments.Err(); err != nil {
+						return checkPartial(vk.SourceError{Source: "comments of " + _commentsPostURL, Err: err})
+					}
+					return nil
+				},
+				f.Identity[error],
+			)
+		},
+	}
+)
+
+// newCommentEncoder makes encoder of comments in output format. In json formats replies are nested
+// in their top level comments, other formats have comment per record.
+func newCommentEncoder(printer commentPrinter) (output.Encoder[vk.Comment], error) {
+	switch outputFormat {
+	case output.FormatJSON, output.FormatNDJSON:
+		enc, err := output.New[commentTree](os.Stdout, outputFormat, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &treeEncoder{enc: enc}, nil
+	default:
+		return newEncoder(output.CommentColumns, textEncoder[vk.Comment]{print: printer.print, close: printer.close})
+	}
+}
+
+// commentTree is top level comment with replies in its thread.
+type commentTree struct {
+	vk.Comment
+	Replies []vk.Comment `json:"replies"`
+}
+
+// treeEncoder groups replies with their top level comment, encoding one tree at a time.
+type treeEncoder struct {
+	enc     output.Encoder[commentTree]
+	current *commentTree
+}
+
+func (enc *treeEncoder) flush() error {
+	if enc.current == nil {
+		return nil
+	}
+	tree := *enc.current
+	enc.current = nil
+	return enc.enc.Encode(tree)
+}
+
+func (enc *treeEncoder) Encode(comment vk.Comment) error {
+	if comment.ParentID != 0 && enc.current != nil && enc.current.ID == comment.ParentID {
+		enc.current.Replies = append(enc.current.Replies, comment)
+		return nil
+	}
+	if err := enc.flush(); err != nil {
+		return err
+	}
+	enc.current = &commentTree{Comment: comment, Replies: []vk.Comment{}}
+	return nil
+}
+
+func (enc *treeEncoder) Close() error {
+	if err := enc.flush(); err != nil {
+		return err
+	}
+	return enc.enc.Close()
+}
+
+// commentPrinter prints comments in order of vkutils.VKClient.Comments stream.
+type commentPrinter interface {
+	print(vk.Comment)
+	close() error
+}
+
+// threadDepths tracks how deep replies are nested in current thread.
+type threadDepths map[uint]int
+
+// depth returns depth of comment: zero for top level comments, replies to replies are nested deeper.
+func (depths threadDepths) depth(comment vk.Comment) int {
+	if comment.ParentID == 0 {
+		for id := range depths {
+			delete(depths, id)
+		}
+		depths[comment.ID] = 0
+		return 0
+	}
+	depth := 1
+	if parentDepth, ok := depths[comment.ReplyToComment]; ok && comment.ReplyToComment != comment.ParentID {
+		depth = parentDepth + 1
+	}
+	depths[comment.ID] = depth
+	return depth
+}
+
+func formatDate(date uint) string {
+	return time.Unix(int64(date), 0).Format(time.DateTime)
+}
+
+type treePrinter struct {
+	depths threadDepths
+}
+
+func (p *treePrinter) print(comment vk.Comment) {
+	if p.depths == nil {
+		p.depths = threadDepths{}
+	}
+	indent := strings.Repeat("    ", p.depths.depth(comment))
+	fmt.Printf("%s#%d %s (%s) likes: %d\n", indent, comment.ID, comment.Author, formatDate(comment.Date), comment.Likes.Count)
+	if comment.Text != "" {
+		fmt.Printf("%s%s\n", indent, strings.ReplaceAll(comment.Text, "\n", "\n"+indent))
+	}
+	for _, a := range comment.Attachments {
+		fmt.Printf("%s[%s] %s\n", indent, a.Type, a.URL())
+	}
+}
+
+func (p *treePrinter) close() error {
+	return nil
+}
+
+type markdownPrinter struct {
+	depths threadDepths
+}
+
+func (p *markdownPrinter) print(comment vk.Comment) {
+	if p.depths == nil {
+		p.depths = threadDepths{}
+	}
+	depth := p.depths.depth(comment)
+	quote := strings.Repeat("> ", depth)
+	if depth == 0 {
+		fmt.Println("---")
+	}
+	fmt.Printf("%s**[%s](%s)** · [%s](%s) · ♥ %d\n", quote, comment.Author, comment.Author.URL(), formatDate(comment.Date), comment.URL(), comment.Likes.Count)
+	fmt.Println(strings.TrimSpace(quote))
+	if comment.Text != "" {
+		fmt.Printf("%s%s\n", quote, strings.ReplaceAll(comment.Text, "\n", "\n"+quote))
+	}
+	for _, a := range comment.Attachments {
+		fmt.Printf("%s- %s: %s\n", quote, a.Type, a.URL())
+	}
+	fmt.Println()
+}
+
+func (p *markdownPrinter) close() error {
+	return nil
+}
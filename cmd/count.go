@@ -2,12 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 
 	r "github.com/rprtr258/go-flow/result"
 	s "github.com/rprtr258/go-flow/stream"
 	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/output"
+	"github.com/rprtr258/vk-utils/pkg/resolve"
 	"github.com/urfave/cli/v2"
 )
 
@@ -18,119 +21,447 @@ var (
 	_followers      = cli.NewStringSlice()
 	_postCommenters = cli.NewStringSlice()
 	_userProvided   = cli.NewStringSlice()
+	_managers       = cli.NewStringSlice()
+	_memberFriends  = cli.NewStringSlice()
+	_pollVoters     = cli.NewStringSlice()
+	_mutualFriends  = cli.NewStringSlice()
+	_photoLikers    = cli.NewStringSlice()
+	_videoLikers    = cli.NewStringSlice()
+	_commentLikers  = cli.NewStringSlice()
+	_userFields     = cli.NewStringSlice()
+	_countExpr      string
+	_showSources    bool
+	_matrix         bool
+	_minCount       uint
+	_maxCount       uint
+	_exactCount     uint
+	_top            uint
+	_sortBy         string
+	_noDeactivated  bool
+	_weightsFile    string
+	_scoring        string
 	countCmd        = &cli.Command{
 		Name: "count",
 		Usage: `Counts how many sets users belong to. Useful for uniting and intersecting user sets.
 Example:
 	vkutils count --friends 168715495 --groups -187839235 --post-likers 107904132_1371
+	vkutils count --expr '(group:-187839235 & likers:107904132_1371) - friends:168715495 | users:1,2,3'
+	vkutils count --scoring engagement --groups -187839235 --post-likers 107904132_1371:5 --commenters 107904132_1371
 `,
 		Action: run,
 		Flags: []cli.Flag{
 			&cli.StringSliceFlag{
 				Destination: _groups,
 				Name:        "groups",
-				Aliases:     []string{"g"},
-				Usage:       "group id members of which to scan",
+				Aliases:     []string{"g", "subscribers"},
+				Usage:       "group or public page link, screen name or id members of which to scan, members of public page are its subscribers",
 			},
 			&cli.StringSliceFlag{
 				Destination: _friends,
 				Name:        "friends",
 				Aliases:     []string{"r"},
-				Usage:       "user id friends of which to scan",
+				Usage:       "user link, screen name or id friends of which to scan",
 			},
 			&cli.StringSliceFlag{
 				Destination: _followers,
 				Name:        "followers",
 				Aliases:     []string{"w"},
-				Usage:       "user id followers of which to scan",
+				Usage:       "user link, screen name or id followers of which to scan",
 			},
 			&cli.StringSliceFlag{
 				Destination: _postLikers,
 				Name:        "post-likers",
 				Aliases:     []string{"l"},
-				Usage:       "post id likers of which to scan",
+				Usage:       "post link or id likers of which to scan",
 			},
 			&cli.StringSliceFlag{
 				Destination: _postCommenters,
 				Name:        "commenters",
 				Aliases:     []string{"c"},
-				Usage:       "post id commenters of which to scan",
+				Usage:       "post link or id commenters of which to scan",
 			},
 			&cli.StringSliceFlag{
 				Destination: _userProvided,
 				Name:        "users",
 				Aliases:     []string{"u"},
-				Usage:       "user ids to scan",
+				Usage:       "user links, screen names or ids to scan",
+			},
+			&cli.StringSliceFlag{
+				Destination: _managers,
+				Name:        "managers",
+				Usage:       "group link, screen name or id managers of which to scan",
+			},
+			&cli.StringSliceFlag{
+				Destination: _memberFriends,
+				Name:        "member-friends",
+				Usage:       "group link, screen name or id members of which that are friends of token owner to scan",
+			},
+			&cli.StringSliceFlag{
+				Destination: _pollVoters,
+				Name:        "poll-voters",
+				Usage:       "poll link or id voters of which to scan, e.g. poll-1_2, or poll-1_2_3 for voters for answer 3",
+			},
+			&cli.StringSliceFlag{
+				Destination: _mutualFriends,
+				Name:        "mutual-friends",
+				Usage:       "two users joined with + mutual friends of which to scan, e.g. durov+id1",
+			},
+			&cli.StringSliceFlag{
+				Destination: _photoLikers,
+				Name:        "photo-likers",
+				Usage:       "photo link or id likers of which to scan",
+			},
+			&cli.StringSliceFlag{
+				Destination: _videoLikers,
+				Name:        "video-likers",
+				Usage:       "video link or id likers of which to scan",
+			},
+			&cli.StringSliceFlag{
+				Destination: _commentLikers,
+				Name:        "comment-likers",
+				Usage:       "comment link or id likers of which to scan, e.g. wall-1_2_r3",
+			},
+			&cli.StringSliceFlag{
+				Destination: _userFields,
+				Name:        "fields",
+				Aliases:     []string{"f"},
+				Usage:       "extra profile fields to fetch and print, e.g. screen_name,sex,city",
+			},
+			&cli.StringFlag{
+				Destination: &_countExpr,
+				Name:        "expr",
+				Aliases:     []string{"e"},
+				Usage: `set expression to find users of instead of source flags, sources are written as
+	friends:ref, group:ref, followers:ref, users:ref, likers:ref, commenters:ref, managers:ref, member-friends:ref,
+	voters:ref, mutual:user+user, subscribers:ref, photo-likers:ref, video-likers:ref or comment-likers:ref, comma separated refs are united,
+	sets are combined with | (union), & (intersection), - (difference, surrounded by spaces or followed by source or "("), ^ (symmetric difference)
+	and atleast(N, expr, expr, ...) (users in at least N of sets)`,
+			},
+			&cli.BoolFlag{
+				Destination: &_showSources,
+				Name:        "sources",
+				Aliases:     []string{"s"},
+				Usage:       "print sources every user is in",
+			},
+			&cli.BoolFlag{
+				Destination: &_matrix,
+				Name:        "matrix",
+				Aliases:     []string{"m"},
+				Usage:       "print membership matrix with column per source",
+			},
+			&cli.UintFlag{
+				Destination: &_minCount,
+				Name:        "min",
+				Usage:       "print only users in at least that many sources",
+			},
+			&cli.UintFlag{
+				Destination: &_maxCount,
+				Name:        "max",
+				Usage:       "print only users in at most that many sources, 0 means no limit",
+			},
+			&cli.UintFlag{
+				Destination: &_exactCount,
+				Name:        "exactly",
+				Usage:       "print only users in exactly that many sources",
+			},
+			&cli.UintFlag{
+				Destination: &_top,
+				Name:        "top",
+				Aliases:     []string{"t"},
+				Usage:       "print only that many users in most sources, 0 means all",
+			},
+			&cli.StringFlag{
+				Destination: &_sortBy,
+				Name:        "sort",
+				Value:       "id",
+				Usage:       "order of users with same score and number of sources: id or name",
+			},
+			&cli.BoolFlag{
+				Destination: &_noDeactivated,
+				Name:        "exclude-deactivated",
+				Usage:       "skip deleted and banned users and groups",
+			},
+			&cli.StringFlag{
+				Destination: &_weightsFile,
+				Name:        "weights",
+				Usage: `file with weights of sources in user score, every line is source or kind of sources and weight,
+	e.g. "likers:107904132_1371 5" or "commenters 3"; weight of single source can also be given after its link,
+	e.g. --post-likers 107904132_1371:5 or likers:107904132_1371:5 in --expr`,
+			},
+			&cli.StringFlag{
+				Destination: &_scoring,
+				Name:        "scoring",
+				Value:       "count",
+				Usage:       "default weights of sources: count (all weigh 1) or engagement (commenters 3, likers of posts, photos, videos and comments 2, others 1)",
 			},
 		},
 	}
 )
 
-func parseUserIDsList(ls []string) r.Result[[]vk.UserID] {
-	res := make([]vk.UserID, 0, len(ls))
-	for _, id := range ls {
-		userID, err := strconv.ParseInt(id, 10, 32)
-		if err != nil {
-			return r.Err[[]vk.UserID](fmt.Errorf("error parsing user id: %s", id))
-		}
-		res = append(res, vk.UserID(userID))
-	}
-	return r.Success(res)
-}
-
-func parsePostsList(ls []string) r.Result[[]vk.PostID] {
-	res := make([]vk.PostID, 0, len(ls))
-	for _, id := range ls {
-		var (
-			ownerID vk.UserID
-			postID  uint
-		)
-		if _, err := fmt.Sscanf(id, "%d_%d", &ownerID, &postID); err != nil {
-			return r.Err[[]vk.PostID](fmt.Errorf("error parsing post id: %s", id))
-		}
-		res = append(res, vk.PostID{
-			OwnerID: ownerID,
-			ID:      postID,
-		})
-	}
-	return r.Success(res)
-}
-
 func appendIfError[A any](errors *[]error, x r.Result[A]) {
 	if x.IsErr() {
 		*errors = append(*errors, x.UnwrapErr())
 	}
 }
 
-func run(*cli.Context) error {
+func run(ctx *cli.Context) error {
 	var errors []error
 
-	groupIDs := parseUserIDsList(_groups.Value())
+	weights := vk.Weights{Sources: map[vk.Source]float64{}, Kinds: map[vk.SourceKind]float64{}}
+	switch _scoring {
+	case "count":
+	case "engagement":
+		for kind, weight := range vk.EngagementWeights.Kinds {
+			weights.Kinds[kind] = weight
+		}
+	default:
+		errors = append(errors, fmt.Errorf("unknown scoring %q, expected count or engagement", _scoring))
+	}
+	if _weightsFile != "" {
+		if err := readWeights(ctx.Context, _weightsFile, weights); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	groupIDs := resolveWeighted(ctx.Context, _groups.Value(), resolve.Group, func(id vk.GroupID) vk.Source {
+		return vk.Source{Kind: vk.SourceGroupMembers, Group: id}
+	}, weights.Sources)
 	appendIfError(&errors, groupIDs)
-	friendIDs := parseUserIDsList(_friends.Value())
+	friendIDs := resolveWeighted(ctx.Context, _friends.Value(), resolve.User, func(id vk.UserID) vk.Source {
+		return vk.Source{Kind: vk.SourceFriends, User: id}
+	}, weights.Sources)
 	appendIfError(&errors, friendIDs)
-	followerIDs := parseUserIDsList(_followers.Value())
+	followerIDs := resolveWeighted(ctx.Context, _followers.Value(), resolve.User, func(id vk.UserID) vk.Source {
+		return vk.Source{Kind: vk.SourceFollowers, User: id}
+	}, weights.Sources)
 	appendIfError(&errors, followerIDs)
-	userIDs := parseUserIDsList(_userProvided.Value())
+	userIDs := resolveWeighted(ctx.Context, _userProvided.Value(), resolve.User, func(id vk.UserID) vk.Source {
+		return vk.Source{Kind: vk.SourceUser, User: id}
+	}, weights.Sources)
 	appendIfError(&errors, userIDs)
-	postLikerIDs := parsePostsList(_postLikers.Value())
+	postLikerIDs := resolveWeighted(ctx.Context, _postLikers.Value(), resolve.Post, func(id vk.PostID) vk.Source {
+		return vk.Source{Kind: vk.SourceLikers, Post: id}
+	}, weights.Sources)
 	appendIfError(&errors, postLikerIDs)
-	postCommenterIDs := parsePostsList(_postCommenters.Value())
+	postCommenterIDs := resolveWeighted(ctx.Context, _postCommenters.Value(), resolve.Post, func(id vk.PostID) vk.Source {
+		return vk.Source{Kind: vk.SourceCommenters, Post: id}
+	}, weights.Sources)
 	appendIfError(&errors, postCommenterIDs)
+	managerGroupIDs := resolveWeighted(ctx.Context, _managers.Value(), resolve.Group, func(id vk.GroupID) vk.Source {
+		return vk.Source{Kind: vk.SourceGroupManagers, Group: id}
+	}, weights.Sources)
+	appendIfError(&errors, managerGroupIDs)
+	memberFriendGroupIDs := resolveWeighted(ctx.Context, _memberFriends.Value(), resolve.Group, func(id vk.GroupID) vk.Source {
+		return vk.Source{Kind: vk.SourceMemberFriends, Group: id}
+	}, weights.Sources)
+	appendIfError(&errors, memberFriendGroupIDs)
+	pollAnswerIDs := resolveWeighted(ctx.Context, _pollVoters.Value(), resolve.Poll, func(id vk.PollAnswerID) vk.Source {
+		return vk.Source{Kind: vk.SourcePollVoters, Poll: id}
+	}, weights.Sources)
+	appendIfError(&errors, pollAnswerIDs)
+	mutualFriendIDs := resolveWeighted(ctx.Context, _mutualFriends.Value(), resolve.UserPair, func(users [2]vk.UserID) vk.Source {
+		return vk.Source{Kind: vk.SourceMutualFriends, User: users[0], Target: users[1]}
+	}, weights.Sources)
+	appendIfError(&errors, mutualFriendIDs)
+	photoLikerIDs := resolveWeighted(ctx.Context, _photoLikers.Value(), resolve.Item(resolve.KindPhoto), func(id vk.PostID) vk.Source {
+		return vk.Source{Kind: vk.SourcePhotoLikers, Item: id}
+	}, weights.Sources)
+	appendIfError(&errors, photoLikerIDs)
+	videoLikerIDs := resolveWeighted(ctx.Context, _videoLikers.Value(), resolve.Item(resolve.KindVideo), func(id vk.PostID) vk.Source {
+		return vk.Source{Kind: vk.SourceVideoLikers, Item: id}
+	}, weights.Sources)
+	appendIfError(&errors, videoLikerIDs)
+	commentLikerIDs := resolveWeighted(ctx.Context, _commentLikers.Value(), resolve.Item(resolve.KindComment), func(id vk.PostID) vk.Source {
+		return vk.Source{Kind: vk.SourceCommentLikers, Item: id}
+	}, weights.Sources)
+	appendIfError(&errors, commentLikerIDs)
+
+	if err := vk.ValidateUserFields(_userFields.Value()); err != nil {
+		errors = append(errors, err)
+	}
+
+	filter := vk.CountFilter{
+		Min:                _minCount,
+		Max:                _maxCount,
+		Top:                _top,
+		ExcludeDeactivated: _noDeactivated,
+		Weights:            weights,
+	}
+	if ctx.IsSet("exactly") {
+		if ctx.IsSet("min") || ctx.IsSet("max") {
+			errors = append(errors, fmt.Errorf("--exactly can't be used together with --min or --max"))
+		}
+		if _exactCount == 0 {
+			errors = append(errors, fmt.Errorf("--exactly must be positive"))
+		}
+		filter.Min, filter.Max = _exactCount, _exactCount
+	}
+	switch _sortBy {
+	case "id":
+		filter.Order = vk.OrderByID
+	case "name":
+		filter.Order = vk.OrderByName
+	default:
+		errors = append(errors, fmt.Errorf("unknown sort order %q, expected id or name", _sortBy))
+	}
+
+	sets := vk.UserSets{}
+	if errors == nil {
+		sets = vk.UserSets{
+			GroupMembers:  groupIDs.Unwrap(),
+			Friends:       friendIDs.Unwrap(),
+			Followers:     followerIDs.Unwrap(),
+			Users:         userIDs.Unwrap(),
+			Likers:        postLikerIDs.Unwrap(),
+			Commenters:    postCommenterIDs.Unwrap(),
+			Managers:      managerGroupIDs.Unwrap(),
+			MemberFriends: memberFriendGroupIDs.Unwrap(),
+			PollVoters:    pollAnswerIDs.Unwrap(),
+			MutualFriends: mutualFriendIDs.Unwrap(),
+			PhotoLikers:   photoLikerIDs.Unwrap(),
+			VideoLikers:   videoLikerIDs.Unwrap(),
+			CommentLikers: commentLikerIDs.Unwrap(),
+		}
+	}
+
+	var expr r.Result[vk.SetExpr]
+	if _countExpr != "" {
+		if len(sets.Sources()) > 0 {
+			errors = append(errors, fmt.Errorf("--expr can't be used together with source flags"))
+		}
+		expr = vk.ParseSetExpr(_countExpr, func(kind vk.SourceKind, ref string) r.Result[vk.Source] {
+			ref, weight, err := splitWeight(ref)
+			if err != nil {
+				return r.Err[vk.Source](err)
+			}
+			return r.Map(resolve.Source(ctx.Context, client, kind, ref), func(source vk.Source) vk.Source {
+				if weight.IsSome() {
+					weights.Sources[source] = weight.Unwrap()
+				}
+				return source
+			})
+		})
+		appendIfError(&errors, expr)
+	}
 
 	if errors != nil {
 		return fmt.Errorf(strings.Join(s.CollectToSlice(s.Map(s.FromSlice(errors), (error).Error)), "\n"))
 	}
 
-	for _, userInfoCount := range vk.MembershipCount(client, vk.UserSets{
-		GroupMembers: groupIDs.Unwrap(),
-		Friends:      friendIDs.Unwrap(),
-		Followers:    followerIDs.Unwrap(),
-		Users:        userIDs.Unwrap(),
-		Likers:       postLikerIDs.Unwrap(),
-		Commenters:   postCommenterIDs.Unwrap(),
-	}) {
-		fmt.Printf("%d: %s %s - %d\n", userInfoCount.Left.ID, userInfoCount.Left.FirstName, userInfoCount.Left.SecondName, userInfoCount.Right)
+	// user fields are requested only by count, so shared client is left as is
+	countClient := client
+	vk.WithUserFields(_userFields.Value()...)(&countClient)
+
+	var res vk.MembershipCountResult
+	if _countExpr != "" {
+		res = vk.EvalSetExpr(ctx.Context, countClient, expr.Unwrap(), filter)
+	} else {
+		res = vk.MembershipCount(ctx.Context, countClient, sets, filter)
+	}
+	if err := printCounts(res, weights); err != nil {
+		return err
+	}
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+	return checkPartial(res.Incomplete...)
+}
+
+// countRecord is user or group with sources they are in.
+type countRecord struct {
+	Owner   vk.Owner    `json:"owner"`
+	Count   uint        `json:"count"`
+	Score   float64     `json:"score"`
+	Sources []vk.Source `json:"sources"`
+	// in tells whether owner is in source with same index in result sources.
+	in []bool
+}
+
+// countColumns are columns of owner profile, count and, if requested, sources owner is in
+// or membership matrix with column per source.
+func countColumns(sources []vk.Source) []output.Column[countRecord] {
+	columns := output.MapColumns(output.OwnerColumns(_userFields.Value()...), func(record countRecord) vk.Owner {
+		return record.Owner
+	})
+	columns = append(columns, output.Column[countRecord]{Name: "count", Value: func(record countRecord) string {
+		return fmt.Sprint(record.Count)
+	}}, output.Column[countRecord]{Name: "score", Value: func(record countRecord) string {
+		return strconv.FormatFloat(record.Score, 'f', -1, 64)
+	}})
+	if _showSources {
+		columns = append(columns, output.Column[countRecord]{Name: "sources", Value: func(record countRecord) string {
+			return sourceNames(record.Sources, "; ")
+		}})
+	}
+	if _matrix {
+		for i, source := range sources {
+			i := i
+			columns = append(columns, output.Column[countRecord]{Name: source.String(), Value: func(record countRecord) string {
+				if record.in[i] {
+					return "x"
+				}
+				return ""
+			}})
+		}
+	}
+	return columns
+}
+
+func sourceNames(sources []vk.Source, sep string) string {
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		names[i] = source.String()
+	}
+	return strings.Join(names, sep)
+}
+
+// printCount prints owner with count and, if sources are weighted, score in text format.
+func printCount(record countRecord, weighted bool) {
+	owner := record.Owner
+	fmt.Printf("%d: %s - %d", owner.ID(), owner, record.Count)
+	if weighted {
+		fmt.Printf(" score=%g", record.Score)
+	}
+	if !owner.IsGroup() {
+		for _, field := range _userFields.Value() {
+			fmt.Printf(" %s=%s", field, owner.User.Field(field))
+		}
+	}
+	if _showSources {
+		fmt.Printf(" (%s)", sourceNames(record.Sources, ", "))
+	}
+	fmt.Println()
+}
+
+func printCounts(res vk.MembershipCountResult, weights vk.Weights) error {
+	columns := countColumns(res.Sources)
+	weighted := len(weights.Sources) > 0 || len(weights.Kinds) > 0
+	enc, err := newEncoder(columns, textEncoder[countRecord]{print: func(record countRecord) {
+		printCount(record, weighted)
+	}})
+	if outputFormat == output.FormatText && _matrix {
+		// matrix is table even in text format
+		enc, err = output.New(os.Stdout, output.FormatTable, columns, nil)
+	}
+	if err != nil {
+		return err
+	}
+	for _, membership := range res.Counts {
+		record := countRecord{
+			Owner:   membership.Owner,
+			Count:   membership.Count,
+			Score:   membership.Score,
+			Sources: []vk.Source{},
+			in:      membership.In,
+		}
+		for i, in := range membership.In {
+			if in {
+				record.Sources = append(record.Sources, res.Sources[i])
+			}
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
 	}
-	return nil
+	return enc.Close()
 }
@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	f "github.com/rprtr258/go-flow/fun"
 	r "github.com/rprtr258/go-flow/result"
-	s "github.com/rprtr258/go-flow/stream"
 	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/output"
+	"github.com/rprtr258/vk-utils/pkg/resolve"
 	"github.com/urfave/cli/v2"
 )
 
@@ -23,40 +26,66 @@ Example:
 				Name:        "url",
 				Aliases:     []string{"u"},
 				Required:    true,
-				Usage:       "url of vk group",
+				Usage:       "link, screen name or id of vk group or user",
 				Destination: &_groupURL,
 			},
 		},
-		Action: func(*cli.Context) error {
-			groupName := parseGroupURL(_groupURL)
-			vk.GetPosts(client, groupName.Unwrap()).Consume(
-				func(x s.Stream[vk.Post]) {
-					s.ForEach(
-						x,
-						func(p vk.Post) {
-							fmt.Printf("https://vk.com/wall%d_%d\n", p.Owner, p.ID)
-							fmt.Println("Date: ", time.Unix(int64(p.Date), 0))
-							fmt.Println(p.Text)
-							if len(p.CopyHistory) > 0 {
-								fmt.Println("Repost: ", p.CopyHistory[0])
-							}
-							fmt.Println()
-						},
-					)
-				},
-				func(err error) {
-					fmt.Printf("error: %v\n", err)
+		Action: func(ctx *cli.Context) error {
+			posts := r.Map(resolve.Owner(ctx.Context, client, _groupURL), func(ownerID vk.OwnerID) vk.ErrStream[vk.Post] {
+				return vk.GetPosts(ctx.Context, client, ownerID)
+			})
+			return r.Fold(
+				posts,
+				func(x vk.ErrStream[vk.Post]) error {
+					enc, err := newEncoder(output.PostColumns, textEncoder[vk.Post]{print: func(p vk.Post) {
+						printPost(p, "")
+						fmt.Println()
+					}})
+					if err != nil {
+						return err
+					}
+					if err := encodeAll[vk.Post](x, enc); err != nil {
+						return err
+					}
+					if err := ctxErr(ctx); err != nil {
+						return err
+					}
+					if err := x.Err(); err != nil {
+						return checkPartial(vk.SourceError{Source: "posts of " + _groupURL, Err: err})
+					}
+					return nil
 				},
+				f.Identity[error],
 			)
-			return nil
 		},
 	}
 )
 
-func parseGroupURL(groupURL string) r.Result[string] {
-	var groupName string
-	if _, err := fmt.Sscanf(groupURL, "https://vk.com/%s", &groupName); err != nil {
-		return r.Err[string](err)
+// printPost prints post with reposted posts indented.
+func printPost(p vk.Post, indent string) {
+	fmt.Printf("%s%s\n", indent, p.URL())
+	fmt.Printf("%sDate: %s\n", indent, time.Unix(int64(p.Date), 0))
+	if p.Edited != 0 {
+		fmt.Printf("%sEdited: %s\n", indent, time.Unix(int64(p.Edited), 0))
+	}
+	if p.IsPinned {
+		fmt.Printf("%sPinned\n", indent)
+	}
+	if p.MarkedAsAds {
+		fmt.Printf("%sAd\n", indent)
+	}
+	if p.Text != "" {
+		fmt.Printf("%s%s\n", indent, strings.ReplaceAll(p.Text, "\n", "\n"+indent))
+	}
+	for _, a := range p.Attachments {
+		fmt.Printf("%s[%s] %s\n", indent, a.Type, a.URL())
+	}
+	if p.Geo != nil {
+		fmt.Printf("%sGeo: %s\n", indent, p.Geo.Coordinates)
+	}
+	fmt.Printf("%sLikes: %d, reposts: %d, comments: %d, views: %d\n", indent, p.Likes.Count, p.Reposts.Count, p.Comments.Count, p.Views.Count)
+	for _, repost := range p.CopyHistory {
+		fmt.Printf("%sRepost:\n", indent)
+		printPost(repost, indent+"\t")
 	}
-	return r.Success(groupName)
 }
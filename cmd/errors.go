@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	vk "github.com/rprtr258/vk-utils/pkg"
+)
+
+// exit codes of vkutils
+const (
+	exitError         = 1
+	exitAuthFailed    = 3
+	exitRateLimited   = 4
+	exitAccessDenied  = 5
+	exitCaptcha       = 6
+	exitServerError   = 7
+	exitInvalidParams = 8
+	exitNotFound      = 9
+	exitPartial       = 10
+	exitTimeout       = 124
+	exitInterrupted   = 130
+)
+
+// partialError tells that command output is incomplete because some sources failed.
+type partialError struct {
+	sources []string
+}
+
+func (err partialError) Error() string {
+	return fmt.Sprintf("result is incomplete, %d source(s) failed: %s", len(err.sources), strings.Join(err.sources, ", "))
+}
+
+// checkPartial reports sources that failed and makes partialError unless --allow-partial is set.
+func checkPartial(errs ...vk.SourceError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	sources := make([]string, 0, len(errs))
+	for _, err := range errs {
+		slog.Warn("source is incomplete", "source", err.Source, "error", err.Err)
+		sources = append(sources, err.Source)
+	}
+	if _allowPartial {
+		return nil
+	}
+	return partialError{sources: sources}
+}
+
+// DescribeError makes user friendly message and exit code for error returned by command.
+func DescribeError(err error) (string, int) {
+	var (
+		code       vk.ErrorCode
+		apiErr     vk.ApiCallError
+		hiddenErr  vk.PostHiddenError
+		nameErr    vk.ScreenNameNotFoundError
+		partialErr partialError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "interrupted", exitInterrupted
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out, see --timeout", exitTimeout
+	case errors.As(err, &partialErr):
+		return partialErr.Error() + ", use --allow-partial to accept incomplete results", exitPartial
+	case errors.As(err, &hiddenErr):
+		return fmt.Sprintf("post %d_%d is deleted or hidden", hiddenErr.PostID.OwnerID, hiddenErr.PostID.ID), exitNotFound
+	case errors.As(err, &nameErr):
+		return nameErr.Error(), exitNotFound
+	case !errors.As(err, &code):
+		return err.Error(), exitError
+	}
+
+	method := "VK api"
+	if errors.As(err, &apiErr) {
+		method = apiErr.Method()
+	}
+	switch {
+	case code == vk.ErrAuthFailed:
+		return "authorization failed, check VK api token", exitAuthFailed
+	case code.IsRateLimit():
+		return fmt.Sprintf("%s: %v, try again later or lower --rps", method, code), exitRateLimited
+	case code == vk.ErrCaptchaNeeded:
+		return fmt.Sprintf("%s: captcha needed, try again later or solve it with --captcha prompt", method), exitCaptcha
+	case code.IsAccessError():
+		return fmt.Sprintf("%s: %v", method, code), exitAccessDenied
+	case code == vk.ErrUnknown || code == vk.ErrInternal:
+		return fmt.Sprintf("%s: VK %v, try again later", method, code), exitServerError
+	case code == vk.ErrInvalidParams || code == vk.ErrInvalidUserID:
+		return fmt.Sprintf("%s: %v", method, err), exitInvalidParams
+	case code == vk.ErrPostNotFound:
+		return fmt.Sprintf("%s: %v", method, code), exitNotFound
+	default:
+		return err.Error(), exitError
+	}
+}
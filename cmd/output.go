@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+
+	s "github.com/rprtr258/go-flow/stream"
+	"github.com/rprtr258/vk-utils/pkg/output"
+)
+
+var (
+	outputFormat   output.Format
+	outputTemplate *template.Template
+)
+
+// parseOutput checks --output and --template flags.
+func parseOutput() error {
+	format, err := output.ParseFormat(_output)
+	if err != nil {
+		return err
+	}
+	if _template != "" {
+		if format == output.FormatText {
+			format = output.FormatTemplate
+		}
+		if format != output.FormatTemplate {
+			return fmt.Errorf("--template can't be used with --output %s", format)
+		}
+		if outputTemplate, err = output.ParseTemplate(_template); err != nil {
+			return fmt.Errorf("error parsing template: %w", err)
+		}
+	} else if format == output.FormatTemplate {
+		return fmt.Errorf("--output template requires --template")
+	}
+	outputFormat = format
+	return nil
+}
+
+// textEncoder prints results in human readable form specific to command.
+type textEncoder[A any] struct {
+	print func(A)
+	close func() error
+}
+
+func (enc textEncoder[A]) Encode(a A) error {
+	enc.print(a)
+	return nil
+}
+
+func (enc textEncoder[A]) Close() error {
+	if enc.close == nil {
+		return nil
+	}
+	return enc.close()
+}
+
+// newEncoder makes encoder of command results in format chosen with --output,
+// text format is printed with text.
+func newEncoder[A any](columns []output.Column[A], text textEncoder[A]) (output.Encoder[A], error) {
+	if outputFormat == output.FormatText {
+		return text, nil
+	}
+	return output.New(os.Stdout, outputFormat, columns, outputTemplate)
+}
+
+// encodeAll encodes all values of stream, stopping on first encoding error.
+func encodeAll[A any](xs s.Stream[A], enc output.Encoder[A]) error {
+	for x := xs.Next(); x.IsSome(); x = xs.Next() {
+		if err := enc.Encode(x.Unwrap()); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
@@ -5,8 +5,9 @@ import (
 
 	f "github.com/rprtr258/go-flow/fun"
 	r "github.com/rprtr258/go-flow/result"
-	s "github.com/rprtr258/go-flow/stream"
 	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/output"
+	"github.com/rprtr258/vk-utils/pkg/resolve"
 	"github.com/urfave/cli/v2"
 )
 
@@ -19,23 +20,32 @@ var (
 Example:
 	vkutils reposts -u https://vk.com/wall-2158488_651604
 `,
-		Action: func(*cli.Context) error {
+		Action: func(ctx *cli.Context) error {
 			client.RepostSearchLimit = repostSearchLimit
 			sharersStream := r.FlatMap(
-				parsePostURL(_postURL),
-				func(postID vk.PostID) r.Result[s.Stream[vk.PostID]] {
-					return vk.GetReposters(client, postID)
+				resolve.Post(ctx.Context, client, _postURL),
+				func(postID vk.PostID) r.Result[vk.ErrStream[vk.PostID]] {
+					return vk.GetReposters(ctx.Context, client, postID)
 				},
 			)
 			return r.Fold(
 				sharersStream,
-				func(ss s.Stream[vk.PostID]) error {
-					s.ForEach(
-						ss,
-						func(s vk.PostID) {
-							fmt.Printf("https://vk.com/wall%d_%d\n", s.OwnerID, s.ID)
-						},
-					)
+				func(ss vk.ErrStream[vk.PostID]) error {
+					enc, err := newEncoder(output.PostIDColumns, textEncoder[vk.PostID]{print: func(postID vk.PostID) {
+						fmt.Println(postID.URL())
+					}})
+					if err != nil {
+						return err
+					}
+					if err := encodeAll[vk.PostID](ss, enc); err != nil {
+						return err
+					}
+					if err := ctxErr(ctx); err != nil {
+						return err
+					}
+					if err := ss.Err(); err != nil {
+						return checkPartial(vk.SourceError{Source: "reposters of " + _postURL, Err: err})
+					}
 					return nil
 				},
 				f.Identity[error],
@@ -46,7 +56,7 @@ Example:
 				Name:        "url",
 				Aliases:     []string{"u"},
 				Required:    true,
-				Usage:       "url of vk post",
+				Usage:       "link or id of vk post",
 				Destination: &_postURL,
 			},
 			&cli.UintFlag{
@@ -59,11 +69,3 @@ Example:
 		},
 	}
 )
-
-func parsePostURL(url string) r.Result[vk.PostID] {
-	var postID vk.PostID
-	if _, err := fmt.Sscanf(url, "https://vk.com/wall%d_%d", &postID.OwnerID, &postID.ID); err != nil {
-		return r.Err[vk.PostID](err)
-	}
-	return r.Success(postID)
-}
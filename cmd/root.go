@@ -1,19 +1,49 @@
 package cmd
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
 	"time"
 
+	r "github.com/rprtr258/go-flow/result"
 	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/output"
 	"github.com/urfave/cli/v2"
 )
 
 var (
-	client   vk.VKClient
-	_verbose bool
-	_vkToken string
-	start    time.Time
-	RootCmd  = &cli.App{
+	client        vk.VKClient
+	_verbose      bool
+	_logLevel     string
+	_logFormat    string
+	_logFile      string
+	logFile       io.Closer
+	_vkTokens     = cli.NewStringSlice()
+	_tokenFile    string
+	_apiURL       string
+	_timeout      time.Duration
+	_rps          float64
+	_batch        int
+	_cacheDir     string
+	_cacheTTL     = cli.NewStringSlice()
+	_offline      bool
+	_record       string
+	_replay       string
+	recorder      *vk.CassetteRecorder
+	_captcha      string
+	_retries      int
+	_retryWait    time.Duration
+	_allowPartial bool
+	_output       string
+	_template     string
+	cancel        context.CancelFunc = func() {}
+	start         time.Time
+	RootCmd       = &cli.App{
 		Name:  "vkutils",
 		Usage: "VK data extraction tools. Need VK_ACCESS_TOKEN env var to work with VK api.",
 		Flags: []cli.Flag{
@@ -21,30 +51,323 @@ var (
 				Name:        "verbose",
 				Aliases:     []string{"v"},
 				Value:       false,
-				Usage:       "log api calls",
+				Usage:       "log api calls, same as --log-level debug",
 				Destination: &_verbose,
 			},
 			&cli.StringFlag{
+				Name:        "log-level",
+				Usage:       "log level: debug, info, warn or error",
+				Value:       "info",
+				Destination: &_logLevel,
+			},
+			&cli.StringFlag{
+				Name:        "log-format",
+				Usage:       "log format: text or json",
+				Value:       "text",
+				Destination: &_logFormat,
+			},
+			&cli.StringFlag{
+				Name:        "log-file",
+				Usage:       "file to append logs to instead of stderr",
+				Destination: &_logFile,
+			},
+			&cli.StringSliceFlag{
 				Name:        "token",
-				Usage:       "VK api token",
-				Required:    true,
+				Usage:       "VK api token, can be repeated or comma separated to spread requests between tokens, not needed in offline mode",
 				EnvVars:     []string{"VK_ACCESS_TOKEN"},
-				Destination: &_vkToken,
+				Destination: _vkTokens,
+			},
+			&cli.StringFlag{
+				Name:        "token-file",
+				Usage:       "file with VK api tokens, one per line",
+				EnvVars:     []string{"VK_TOKEN_FILE"},
+				Destination: &_tokenFile,
+			},
+			&cli.StringFlag{
+				Name:        "api-url",
+				Usage:       "VK api base url",
+				Value:       vk.DefaultAPIURL,
+				EnvVars:     []string{"VK_API_URL"},
+				Destination: &_apiURL,
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Usage:       "stop after given time, e.g. 10m, 0 means no timeout",
+				Destination: &_timeout,
+			},
+			&cli.Float64Flag{
+				Name:        "rps",
+				Usage:       "max api requests per second, 0 means no limit",
+				Value:       vk.DefaultRPS,
+				Destination: &_rps,
+			},
+			&cli.IntFlag{
+				Name:        "batch",
+				Usage:       "max api calls merged into single execute request, 1 disables batching",
+				Value:       vk.DefaultExecuteBatchSize,
+				Destination: &_batch,
+			},
+			&cli.StringFlag{
+				Name:        "cache",
+				Usage:       "directory to cache api responses in",
+				EnvVars:     []string{"VK_CACHE"},
+				Destination: &_cacheDir,
+			},
+			&cli.StringSliceFlag{
+				Name:        "cache-ttl",
+				Usage:       "how long cached responses of method are fresh, e.g. wall.get=30m",
+				Destination: _cacheTTL,
+			},
+			&cli.BoolFlag{
+				Name:        "offline",
+				Usage:       "serve all responses from cache, never calling api",
+				Destination: &_offline,
+			},
+			&cli.IntFlag{
+				Name:        "retries",
+				Usage:       "max retries of request failed due to network, server or internal VK error",
+				Value:       vk.DefaultRetryPolicy.MaxAttempts - 1,
+				Destination: &_retries,
+			},
+			&cli.DurationFlag{
+				Name:        "retry-max-wait",
+				Usage:       "max time spent retrying single request, 0 means no limit",
+				Value:       vk.DefaultRetryPolicy.MaxElapsed,
+				Destination: &_retryWait,
+			},
+			&cli.StringFlag{
+				Name:        "captcha",
+				Usage:       "what to do when VK asks for captcha: prompt to solve it, fail, or auto to prompt only if stdin is terminal",
+				Value:       "auto",
+				Destination: &_captcha,
+			},
+			&cli.BoolFlag{
+				Name:        "allow-partial",
+				Usage:       "exit successfully even if some sources could not be fetched completely",
+				Destination: &_allowPartial,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "output format: text, table, json, ndjson, csv, tsv or template",
+				Value:       string(output.FormatText),
+				Destination: &_output,
+			},
+			&cli.StringFlag{
+				Name: "template",
+				Usage: `go text/template to print every result with, implies --output template. Results are posts in dump,
+	post ids in reposts and comments in comments, e.g. '{{.ID}} {{.URL}}', and records with .Owner, .Count,
+	.Score and .Sources in count, e.g. '{{.Owner.URL}} {{.Count}} {{join .Sources ", "}}'`,
+				Destination: &_template,
+			},
+			&cli.StringFlag{
+				Name:        "record",
+				Usage:       "write all api requests and responses to cassette file, token is redacted",
+				Destination: &_record,
+			},
+			&cli.StringFlag{
+				Name:        "replay",
+				Usage:       "serve api responses from cassette file written by --record",
+				Destination: &_replay,
 			},
 		},
 		Commands: []*cli.Command{
 			dumpCmd,
 			repostsCmd,
 			countCmd,
+			commentsCmd,
 		},
 		Before: func(ctx *cli.Context) error {
-			client = vk.NewVKClient(_vkToken, _verbose)
+			logger := newLogger()
+			if logger.IsErr() {
+				return logger.UnwrapErr()
+			}
+			slog.SetDefault(logger.Unwrap())
+
+			if err := parseOutput(); err != nil {
+				return err
+			}
+
+			if _timeout > 0 {
+				ctx.Context, cancel = context.WithTimeout(ctx.Context, _timeout)
+			}
+			var cancelCause context.CancelCauseFunc
+			ctx.Context, cancelCause = context.WithCancelCause(ctx.Context)
+			cancelTimeout := cancel
+			cancel = func() {
+				cancelCause(nil)
+				cancelTimeout()
+			}
+			tokens := readTokens()
+			if tokens.IsErr() {
+				return tokens.UnwrapErr()
+			}
+			if len(tokens.Unwrap()) == 0 && !_offline && _replay == "" {
+				return errors.New("VK api token is required, set --token, VK_ACCESS_TOKEN or --token-file")
+			}
+			// no token is needed in offline and replay modes
+			token, otherTokens := "", tokens.Unwrap()
+			if len(otherTokens) > 0 {
+				token, otherTokens = otherTokens[0], otherTokens[1:]
+			}
+			opts := []vk.ClientOption{
+				vk.WithTokens(otherTokens...),
+				vk.WithLogger(logger.Unwrap()),
+				vk.WithAPIURL(_apiURL),
+				vk.WithRateLimit(_rps),
+				vk.WithExecuteBatching(_batch),
+				vk.WithRetryPolicy(vk.RetryPolicy{
+					MaxAttempts: _retries + 1,
+					InitialWait: vk.DefaultRetryPolicy.InitialWait,
+					MaxWait:     vk.DefaultRetryPolicy.MaxWait,
+					MaxElapsed:  _retryWait,
+					Multiplier:  vk.DefaultRetryPolicy.Multiplier,
+				}),
+			}
+			captcha := _captcha
+			if captcha == "auto" {
+				captcha = "fail"
+				if isTerminal(os.Stdin) {
+					captcha = "prompt"
+				}
+			}
+			switch captcha {
+			case "prompt":
+				opts = append(opts, vk.WithCaptchaSolver(vk.NewTerminalCaptchaSolver(os.Stdin, os.Stderr)))
+			case "fail":
+				opts = append(opts, vk.WithCaptchaSolver(vk.NewFailFastCaptchaSolver(cancelCause)))
+			default:
+				return fmt.Errorf("unknown captcha mode: %s", _captcha)
+			}
+			if _batch < 0 {
+				return errors.New("--batch can't be negative")
+			}
+			if _offline && _cacheDir == "" {
+				return errors.New("--offline requires --cache")
+			}
+			if _cacheDir != "" {
+				cache := r.FlatMap(parseCacheTTLs(_cacheTTL.Value()), func(ttls map[string]time.Duration) r.Result[*vk.DiskCache] {
+					return vk.NewDiskCache(_cacheDir, ttls)
+				})
+				if cache.IsErr() {
+					return cache.UnwrapErr()
+				}
+				opts = append(opts, vk.WithCache(cache.Unwrap(), _offline))
+			}
+			if _record != "" && _replay != "" {
+				return errors.New("--record and --replay can't be used together")
+			}
+			if _record != "" {
+				rec := vk.NewCassetteRecorder(_record)
+				if rec.IsErr() {
+					return rec.UnwrapErr()
+				}
+				recorder = rec.Unwrap()
+				opts = append(opts, vk.WithRecorder(recorder))
+			}
+			if _replay != "" {
+				player := vk.LoadCassette(_replay)
+				if player.IsErr() {
+					return player.UnwrapErr()
+				}
+				opts = append(opts, vk.WithReplay(player.Unwrap()))
+			}
+			client = vk.NewVKClient(token, opts...)
 			start = time.Now()
 			return nil
 		},
 		After: func(ctx *cli.Context) error {
-			log.Printf("Time elapsed %v", time.Since(start))
+			cancel()
+			if recorder != nil {
+				if err := recorder.Close(); err != nil {
+					return err
+				}
+			}
+			slog.Info("done", "elapsed", time.Since(start))
+			if logFile != nil {
+				return logFile.Close()
+			}
 			return nil
 		},
 	}
 )
+
+func parseCacheTTLs(ls []string) r.Result[map[string]time.Duration] {
+	res := make(map[string]time.Duration, len(ls))
+	for _, methodTTL := range ls {
+		method, ttl, ok := strings.Cut(methodTTL, "=")
+		if !ok {
+			return r.Err[map[string]time.Duration](fmt.Errorf("error parsing cache ttl, expected method=duration: %s", methodTTL))
+		}
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			return r.Err[map[string]time.Duration](fmt.Errorf("error parsing cache ttl duration: %s", methodTTL))
+		}
+		res[method] = duration
+	}
+	return r.Success(res)
+}
+
+func newLogger() r.Result[*slog.Logger] {
+	var level slog.Level
+	if _verbose {
+		level = slog.LevelDebug
+	} else if err := level.UnmarshalText([]byte(_logLevel)); err != nil {
+		return r.Err[*slog.Logger](fmt.Errorf("error parsing log level: %w", err))
+	}
+
+	var w io.Writer = os.Stderr
+	if _logFile != "" {
+		file, err := os.OpenFile(_logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return r.Err[*slog.Logger](err)
+		}
+		w, logFile = file, file
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch _logFormat {
+	case "text":
+		return r.Success(slog.New(slog.NewTextHandler(w, opts)))
+	case "json":
+		return r.Success(slog.New(slog.NewJSONHandler(w, opts)))
+	default:
+		return r.Err[*slog.Logger](fmt.Errorf("unknown log format: %s", _logFormat))
+	}
+}
+
+func readTokens() r.Result[[]string] {
+	tokens := make([]string, 0, len(_vkTokens.Value()))
+	for _, token := range _vkTokens.Value() {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if _tokenFile == "" {
+		return r.Success(tokens)
+	}
+	content, err := os.ReadFile(_tokenFile)
+	if err != nil {
+		return r.Err[[]string](fmt.Errorf("error reading token file: %w", err))
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			tokens = append(tokens, line)
+		}
+	}
+	return r.Success(tokens)
+}
+
+// isTerminal checks whether file is terminal, not pipe or regular file.
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// ctxErr returns why command context is done, if it is.
+func ctxErr(ctx *cli.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx.Context)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	f "github.com/rprtr258/go-flow/fun"
+	r "github.com/rprtr258/go-flow/result"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/resolve"
+)
+
+// parseWeight parses weight of key, which must be finite non-negative number.
+func parseWeight(key, weight string) (float64, error) {
+	res, err := strconv.ParseFloat(weight, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid weight of %s: %q", key, weight)
+	}
+	if math.IsNaN(res) || math.IsInf(res, 0) || res < 0 {
+		return 0, fmt.Errorf("weight of %s must be finite and non-negative, got %q", key, weight)
+	}
+	return res, nil
+}
+
+// splitWeight splits optional weight suffix from reference, e.g. 1_2:3.
+// Colons of links are not taken for weight since links don't end with number after colon.
+func splitWeight(ref string) (string, f.Option[float64], error) {
+	i := strings.LastIndex(ref, ":")
+	if i == -1 {
+		return ref, f.None[float64](), nil
+	}
+	if _, err := strconv.ParseFloat(ref[i+1:], 64); err != nil {
+		return ref, f.None[float64](), nil
+	}
+	weight, err := parseWeight(ref[:i], ref[i+1:])
+	if err != nil {
+		return "", f.None[float64](), err
+	}
+	return ref[:i], f.Some(weight), nil
+}
+
+// resolveWeighted resolves references with optional weights, weights are added to weights of sources made with source.
+func resolveWeighted[A any](
+	ctx context.Context,
+	refs []string,
+	resolveRef func(context.Context, vk.VKClient, string) r.Result[A],
+	source func(A) vk.Source,
+	weights map[vk.Source]float64,
+) r.Result[[]A] {
+	plainRefs := make([]string, len(refs))
+	refWeights := make([]f.Option[float64], len(refs))
+	for i, ref := range refs {
+		plainRef, weight, err := splitWeight(ref)
+		if err != nil {
+			return r.Err[[]A](err)
+		}
+		plainRefs[i], refWeights[i] = plainRef, weight
+	}
+	return r.Map(resolve.All(ctx, client, plainRefs, resolveRef), func(ids []A) []A {
+		for i, id := range ids {
+			if refWeights[i].IsSome() {
+				weights[source(id)] = refWeights[i].Unwrap()
+			}
+		}
+		return ids
+	})
+}
+
+// readWeights reads weights file. Every line is source or kind of sources followed by weight, e.g.
+//
+//	likers:107904132_1371 5
+//	commenters 3
+//
+// Sources are written as in --expr, lines starting with # are comments.
+func readWeights(ctx context.Context, path string, weights vk.Weights) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading weights file: %w", err)
+	}
+	for i, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("weights file line %d: expected source and weight, got %q", i+1, line)
+		}
+		weight, err := parseWeight(fields[0], fields[1])
+		if err != nil {
+			return fmt.Errorf("weights file line %d: %w", i+1, err)
+		}
+		name, ref, isSource := strings.Cut(fields[0], ":")
+		kind, err := vk.ParseSourceKind(name)
+		if err != nil {
+			return fmt.Errorf("weights file line %d: %w", i+1, err)
+		}
+		if !isSource {
+			weights.Kinds[kind] = weight
+			continue
+		}
+		source := resolve.Source(ctx, client, kind, ref)
+		if source.IsErr() {
+			return fmt.Errorf("weights file line %d: %w", i+1, source.UnwrapErr())
+		}
+		weights.Sources[source.Unwrap()] = weight
+	}
+	return nil
+}
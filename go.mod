@@ -1,6 +1,6 @@
 module github.com/rprtr258/vk-utils
 
-go 1.18
+go 1.21
 
 require (
 	github.com/rprtr258/go-flow v0.0.0-20220625194404-5e5b4a1357a6
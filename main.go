@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rprtr258/vk-utils/cmd"
 )
 
 func main() {
-	if err := cmd.RootCmd.Run(os.Args); err != nil {
-		log.Fatal(err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd.RootCmd.RunContext(ctx, os.Args); err != nil {
+		stop()
+		message, exitCode := cmd.DescribeError(err)
+		log.Println(message)
+		os.Exit(exitCode)
 	}
 }
@@ -0,0 +1,148 @@
+package vkutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	f "github.com/rprtr258/go-flow/fun"
+	r "github.com/rprtr258/go-flow/result"
+)
+
+// DefaultCacheTTL is how long responses of methods missing in DefaultCacheTTLs are fresh.
+const DefaultCacheTTL = time.Hour
+
+// DefaultCacheTTLs are how long responses of api methods are fresh.
+var DefaultCacheTTLs = map[string]time.Duration{
+	"groups.getMembers":       24 * time.Hour,
+	"groups.getById":          7 * 24 * time.Hour,
+	"friends.get":             24 * time.Hour,
+	"users.getFollowers":      24 * time.Hour,
+	"likes.getList":           6 * time.Hour,
+	"wall.getComments":        6 * time.Hour,
+	"wall.getById":            24 * time.Hour,
+	"wall.get":                time.Hour,
+	"utils.resolveScreenName": 7 * 24 * time.Hour,
+}
+
+// ErrNotCached is returned in offline mode when response is not in cache.
+type ErrNotCached struct {
+	Method string
+	Params url.Values
+}
+
+func (err ErrNotCached) Error() string {
+	return fmt.Sprintf("response of %s(%v) is not cached", err.Method, err.Params)
+}
+
+type cacheEntry struct {
+	Key      string          `json:"key"`
+	StoredAt time.Time       `json:"stored_at"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// DiskCache stores api responses as files in directory.
+type DiskCache struct {
+	dir  string
+	ttls map[string]time.Duration
+}
+
+// NewDiskCache creates cache in given directory, creating it if needed.
+// TTLs override DefaultCacheTTLs for given methods.
+func NewDiskCache(dir string, ttls map[string]time.Duration) r.Result[*DiskCache] {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return r.Err[*DiskCache](err)
+	}
+	cacheTTLs := make(map[string]time.Duration, len(DefaultCacheTTLs)+len(ttls))
+	for method, ttl := range DefaultCacheTTLs {
+		cacheTTLs[method] = ttl
+	}
+	for method, ttl := range ttls {
+		cacheTTLs[method] = ttl
+	}
+	return r.Success(&DiskCache{
+		dir:  dir,
+		ttls: cacheTTLs,
+	})
+}
+
+// cacheKey is method with sorted params, access token and captcha answer excluded.
+func cacheKey(method string, params url.Values) string {
+	keyParams := cloneValues(params)
+	keyParams.Del("access_token")
+	keyParams.Del("captcha_sid")
+	keyParams.Del("captcha_key")
+	return method + "?" + keyParams.Encode()
+}
+
+func (cache *DiskCache) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(cache.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (cache *DiskCache) ttl(method string) time.Duration {
+	if ttl, ok := cache.ttls[method]; ok {
+		return ttl
+	}
+	return DefaultCacheTTL
+}
+
+// Get finds cached response. Stale responses are returned only if ignoreTTL is set.
+func (cache *DiskCache) Get(method string, params url.Values, ignoreTTL bool) f.Option[[]byte] {
+	key := cacheKey(method, params)
+	data, err := os.ReadFile(cache.path(key))
+	if err != nil {
+		return f.None[[]byte]()
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return f.None[[]byte]()
+	}
+	if !ignoreTTL && time.Since(entry.StoredAt) > cache.ttl(method) {
+		return f.None[[]byte]()
+	}
+	return f.Some([]byte(entry.Body))
+}
+
+// Set stores response.
+func (cache *DiskCache) Set(method string, params url.Values, body []byte) error {
+	key := cacheKey(method, params)
+	data, err := json.Marshal(cacheEntry{
+		Key:      key,
+		StoredAt: time.Now(),
+		Body:     body,
+	})
+	if err != nil {
+		return err
+	}
+	// write to temporary file first so that concurrent readers never see partial entry
+	tmp, err := os.CreateTemp(cache.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), cache.path(key))
+}
+
+// isCacheable checks that response is not transient error, e.g. too many requests.
+func isCacheable(body []byte) bool {
+	errResp := jsonUnmarshal[VkErrorResponse](body)
+	if errResp.IsErr() {
+		return false
+	}
+	code := errResp.Unwrap().Err.Code
+	return code == 0 || code.IsAccessError()
+}
@@ -0,0 +1,86 @@
+package vkutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	s "github.com/rprtr258/go-flow/stream"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+func newCache(t *testing.T, ttls map[string]time.Duration) *vk.DiskCache {
+	t.Helper()
+	cache := vk.NewDiskCache(t.TempDir(), ttls)
+	if cache.IsErr() {
+		t.Fatal(cache.UnwrapErr())
+	}
+	return cache.Unwrap()
+}
+
+func TestCache(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{Walls: map[vk.OwnerID][]vk.Post{1: wall(1, 2)}})
+	defer srv.Close()
+
+	client := srv.Client(vk.WithCache(newCache(t, nil), false))
+	for i := 0; i < 2; i++ {
+		if posts := getPosts(t, client, 1); len(posts) != 2 {
+			t.Errorf("expected 2 posts, got %d", len(posts))
+		}
+	}
+	if calls := srv.Calls("wall.get"); calls != 1 {
+		t.Errorf("expected second request to be served from cache, got %d calls", calls)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{Walls: map[vk.OwnerID][]vk.Post{1: wall(1, 2)}})
+	defer srv.Close()
+
+	client := srv.Client(vk.WithCache(newCache(t, map[string]time.Duration{"wall.get": time.Nanosecond}), false))
+	getPosts(t, client, 1)
+	getPosts(t, client, 1)
+	if calls := srv.Calls("wall.get"); calls != 2 {
+		t.Errorf("expected stale response to be requested again, got %d calls", calls)
+	}
+}
+
+func TestCacheAccessError(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrAccessDenied}},
+	})
+	defer srv.Close()
+
+	// private wall is empty
+	client := srv.Client(vk.WithCache(newCache(t, nil), false))
+	for i := 0; i < 2; i++ {
+		if posts := getPosts(t, client, 1); len(posts) != 0 {
+			t.Errorf("expected no posts, got %d", len(posts))
+		}
+	}
+	if calls := srv.Calls("wall.get"); calls != 1 {
+		t.Errorf("expected access error to be cached, got %d calls", calls)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{Walls: map[vk.OwnerID][]vk.Post{1: wall(1, 2)}})
+	cache := newCache(t, map[string]time.Duration{"wall.get": time.Nanosecond})
+	getPosts(t, srv.Client(vk.WithCache(cache, false)), 1)
+	srv.Close()
+
+	// stale responses are served in offline mode and api is never called
+	client := vk.NewVKClient("", vk.WithAPIURL(srv.APIURL()), vk.WithCache(cache, true))
+	if posts := getPosts(t, client, 1); len(posts) != 2 {
+		t.Errorf("expected 2 posts, got %d", len(posts))
+	}
+
+	stream := vk.GetPosts(context.Background(), client, 2)
+	s.CollectToSlice[vk.Post](stream)
+	var notCached vk.ErrNotCached
+	if err := stream.Err(); !errors.As(err, &notCached) || notCached.Method != "wall.get" {
+		t.Errorf("expected not cached error, got %v", err)
+	}
+}
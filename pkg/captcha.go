@@ -0,0 +1,94 @@
+package vkutils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	r "github.com/rprtr258/go-flow/result"
+)
+
+// CaptchaSolver solves captcha VK asks for on error 14. Failed request is retried with its answer.
+type CaptchaSolver interface {
+	// SolveCaptcha gets captcha image url and returns text on it.
+	SolveCaptcha(ctx context.Context, sid, imageURL string) r.Result[string]
+}
+
+type terminalCaptchaSolver struct {
+	mu  sync.Mutex
+	in  *bufio.Reader
+	out io.Writer
+	// lines gets line being read from in, it is kept when solving is canceled,
+	// so that in is never read concurrently
+	lines chan captchaLine
+}
+
+type captchaLine struct {
+	line string
+	err  error
+}
+
+// NewTerminalCaptchaSolver creates solver asking user for captcha answer:
+// it prints captcha image url to out and reads answer line from in.
+func NewTerminalCaptchaSolver(in io.Reader, out io.Writer) CaptchaSolver {
+	return &terminalCaptchaSolver{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+func (solver *terminalCaptchaSolver) SolveCaptcha(ctx context.Context, sid, imageURL string) r.Result[string] {
+	// only one captcha is asked at a time
+	solver.mu.Lock()
+	defer solver.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return r.Err[string](err)
+	}
+
+	if _, err := fmt.Fprintf(solver.out, "VK asks to solve captcha, open %s and enter text from image: ", imageURL); err != nil {
+		return r.Err[string](err)
+	}
+	if solver.lines == nil {
+		lines := make(chan captchaLine, 1)
+		go func() {
+			line, err := solver.in.ReadString('\n')
+			lines <- captchaLine{line: line, err: err}
+		}()
+		solver.lines = lines
+	}
+	var read captchaLine
+	select {
+	case <-ctx.Done():
+		return r.Err[string](ctx.Err())
+	case read = <-solver.lines:
+		solver.lines = nil
+	}
+
+	answer, err := strings.TrimSpace(read.line), read.err
+	if answer == "" {
+		if err == nil {
+			err = errors.New("empty captcha answer")
+		}
+		return r.Err[string](fmt.Errorf("error reading captcha answer: %w", err))
+	}
+	return r.Success(answer)
+}
+
+type failFastCaptchaSolver struct {
+	cancel context.CancelCauseFunc
+}
+
+// NewFailFastCaptchaSolver creates solver that does not solve captcha, but cancels whole run
+// with ErrCaptchaNeeded as cancel cause, so that no data is lost silently.
+func NewFailFastCaptchaSolver(cancel context.CancelCauseFunc) CaptchaSolver {
+	return failFastCaptchaSolver{cancel: cancel}
+}
+
+func (solver failFastCaptchaSolver) SolveCaptcha(context.Context, string, string) r.Result[string] {
+	solver.cancel(ErrCaptchaNeeded)
+	return r.Err[string](ErrCaptchaNeeded)
+}
@@ -0,0 +1,64 @@
+package vkutils_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	s "github.com/rprtr258/go-flow/stream"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+func TestCaptchaRetry(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls:  map[vk.OwnerID][]vk.Post{1: wall(1, 1)},
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrCaptchaNeeded, Times: 1}},
+	})
+	defer srv.Close()
+
+	solver := vk.NewTerminalCaptchaSolver(strings.NewReader("answer\n"), io.Discard)
+	if posts := getPosts(t, srv.Client(vk.WithCaptchaSolver(solver)), 1); len(posts) != 1 {
+		t.Errorf("expected single post, got %d", len(posts))
+	}
+	if calls := srv.Calls("wall.get"); calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+}
+
+func TestCaptchaWithoutSolver(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls:  map[vk.OwnerID][]vk.Post{1: wall(1, 1)},
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrCaptchaNeeded, Times: 1}},
+	})
+	defer srv.Close()
+
+	stream := vk.GetPosts(context.Background(), srv.Client(), 1)
+	s.CollectToSlice[vk.Post](stream)
+	if err := stream.Err(); !errors.Is(err, vk.ErrCaptchaNeeded) {
+		t.Errorf("expected captcha needed error, got %v", err)
+	}
+}
+
+func TestTerminalCaptchaSolverCanceled(t *testing.T) {
+	in, _ := io.Pipe()
+	solver := vk.NewTerminalCaptchaSolver(in, io.Discard)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- solver.SolveCaptcha(ctx, "1", "captcha.png").UnwrapErr()
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("solver is not canceled while waiting for answer")
+	}
+}
@@ -0,0 +1,126 @@
+package vkutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+
+	r "github.com/rprtr258/go-flow/result"
+)
+
+const redactedToken = "REDACTED"
+
+// cassetteEntry is single request/response pair in cassette file.
+type cassetteEntry struct {
+	Method   string          `json:"method"`
+	Params   url.Values      `json:"params"`
+	Response json.RawMessage `json:"response"`
+}
+
+// ErrNotRecorded is returned in replay mode when request is missing in cassette.
+type ErrNotRecorded struct {
+	Method string
+	Params url.Values
+}
+
+func (err ErrNotRecorded) Error() string {
+	return fmt.Sprintf("%s(%v) is not recorded in cassette", err.Method, err.Params)
+}
+
+// CassetteRecorder writes every api request and its response to cassette file,
+// one json object per line. Access token is never written.
+type CassetteRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewCassetteRecorder creates cassette file, truncating it if exists.
+func NewCassetteRecorder(path string) r.Result[*CassetteRecorder] {
+	file, err := os.Create(path)
+	if err != nil {
+		return r.Err[*CassetteRecorder](err)
+	}
+	return r.Success(&CassetteRecorder{
+		file: file,
+		w:    bufio.NewWriter(file),
+	})
+}
+
+func (rec *CassetteRecorder) record(method string, params url.Values, body []byte) error {
+	params = cloneValues(params)
+	if params.Has("access_token") {
+		params.Set("access_token", redactedToken)
+	}
+	line, err := json.Marshal(cassetteEntry{
+		Method:   method,
+		Params:   params,
+		Response: body,
+	})
+	if err != nil {
+		return err
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if _, err := rec.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return rec.w.Flush()
+}
+
+// Close closes cassette file.
+func (rec *CassetteRecorder) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := rec.w.Flush(); err != nil {
+		return err
+	}
+	return rec.file.Close()
+}
+
+// CassettePlayer serves responses recorded in cassette file. Same requests get their responses
+// in recorded order, the last one is repeated when they run out.
+type CassettePlayer struct {
+	mu        sync.Mutex
+	responses map[string][][]byte
+}
+
+// LoadCassette reads cassette file recorded by CassetteRecorder.
+func LoadCassette(path string) r.Result[*CassettePlayer] {
+	file, err := os.Open(path)
+	if err != nil {
+		return r.Err[*CassettePlayer](err)
+	}
+	defer file.Close()
+
+	player := &CassettePlayer{responses: map[string][][]byte{}}
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var entry cassetteEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return r.Err[*CassettePlayer](fmt.Errorf("error reading cassette %s: %w", path, err))
+		}
+		key := cacheKey(entry.Method, entry.Params)
+		player.responses[key] = append(player.responses[key], entry.Response)
+	}
+	return r.Success(player)
+}
+
+func (player *CassettePlayer) play(method string, params url.Values) r.Result[[]byte] {
+	key := cacheKey(method, params)
+
+	player.mu.Lock()
+	defer player.mu.Unlock()
+	responses := player.responses[key]
+	if len(responses) == 0 {
+		return r.Err[[]byte](ErrNotRecorded{Method: method, Params: params})
+	}
+	if len(responses) > 1 {
+		player.responses[key] = responses[1:]
+	}
+	return r.Success(responses[0])
+}
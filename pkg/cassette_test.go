@@ -0,0 +1,66 @@
+package vkutils_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	s "github.com/rprtr258/go-flow/stream"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+func TestCassette(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls:  map[vk.OwnerID][]vk.Post{1: wall(1, 2)},
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrInternal, Times: 1}},
+	})
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	recorder := vk.NewCassetteRecorder(path)
+	if recorder.IsErr() {
+		t.Fatal(recorder.UnwrapErr())
+	}
+	recorded := getPosts(t, srv.Client(vk.WithRecorder(recorder.Unwrap()), fastRetries), 1)
+	if err := recorder.Unwrap().Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 2 {
+		t.Errorf("expected failed and retried requests to be recorded, got %d lines", lines)
+	}
+	if strings.Contains(string(content), "test-token") {
+		t.Error("access token is recorded")
+	}
+
+	player := vk.LoadCassette(path)
+	if player.IsErr() {
+		t.Fatal(player.UnwrapErr())
+	}
+	client := vk.NewVKClient("", vk.WithAPIURL(srv.APIURL()), vk.WithReplay(player.Unwrap()), fastRetries)
+	// responses are replayed in recorded order, so request is retried as it was
+	replayed := getPosts(t, client, 1)
+	if len(replayed) != len(recorded) {
+		t.Fatalf("expected %d posts, got %d", len(recorded), len(replayed))
+	}
+	for i := range replayed {
+		if replayed[i].ID != recorded[i].ID {
+			t.Errorf("expected post %d, got %d", recorded[i].ID, replayed[i].ID)
+		}
+	}
+
+	stream := vk.GetPosts(context.Background(), client, 2)
+	s.CollectToSlice[vk.Post](stream)
+	var notRecorded vk.ErrNotRecorded
+	if err := stream.Err(); !errors.As(err, &notRecorded) {
+		t.Errorf("expected not recorded error, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package vkutils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	f "github.com/rprtr258/go-flow/fun"
+	r "github.com/rprtr258/go-flow/result"
+	s "github.com/rprtr258/go-flow/stream"
+)
+
+// CommentThread is replies to top level comment.
+type CommentThread struct {
+	Count uint `json:"count"`
+}
+
+// Comment is comment under post.
+type Comment struct {
+	ID     uint    `json:"id"`
+	PostID PostID  `json:"post_id"`
+	FromID OwnerID `json:"from_id"`
+	// Author is profile of comment author.
+	Author      Owner        `json:"author"`
+	Date        uint         `json:"date"`
+	Text        string       `json:"text"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Likes       Counter      `json:"likes"`
+	// ReplyToUser is author of comment this comment replies to.
+	ReplyToUser OwnerID `json:"reply_to_user,omitempty"`
+	// ReplyToComment is id of comment this comment replies to.
+	ReplyToComment uint `json:"reply_to_comment,omitempty"`
+	// ParentID is id of top level comment in thread of which comment is, zero for top level comments.
+	ParentID uint          `json:"parent_id,omitempty"`
+	Thread   CommentThread `json:"thread"`
+	Deleted  Bool          `json:"deleted,omitempty"`
+}
+
+// URL returns link to comment.
+func (comment Comment) URL() string {
+	url := fmt.Sprintf("https://vk.com/wall%d_%d?reply=%d", comment.PostID.OwnerID, comment.PostID.ID, comment.ID)
+	if comment.ParentID != 0 {
+		url += fmt.Sprintf("&thread=%d", comment.ParentID)
+	}
+	return url
+}
+
+// threadItemsCount is how many first replies api sends together with top level comment, max allowed is 10.
+const threadItemsCount = 10
+
+type commentItem struct {
+	Comment
+	// api sends post id without owner
+	PostID uint `json:"post_id"`
+	Thread struct {
+		Count uint          `json:"count"`
+		Items []commentItem `json:"items"`
+	} `json:"thread"`
+}
+
+// commentNode is comment with first replies in its thread sent along with it.
+type commentNode struct {
+	comment Comment
+	replies []Comment
+}
+
+type commentsResponse struct {
+	Response struct {
+		Count    uint          `json:"count"`
+		Items    []commentItem `json:"items"`
+		Profiles []User        `json:"profiles"`
+		Groups   []Group       `json:"groups"`
+	} `json:"response"`
+}
+
+// commentsPager pages top level comments of post or replies in thread of comment.
+type commentsPager struct {
+	ctx    context.Context
+	client *VKClient
+	postID PostID
+	// parentID is id of comment replies to which are paged, zero for top level comments.
+	parentID uint
+	params   url.Values
+	offset   uint
+	total    f.Option[uint]
+}
+
+func (pager *commentsPager) NextPage() r.Result[f.Option[[]commentNode]] {
+	if pager.total.IsSome() && pager.offset >= pager.total.Unwrap() {
+		return r.Success(f.None[[]commentNode]())
+	}
+	return r.Map(
+		r.FlatMap(
+			pager.client.apiRequest(pager.ctx, "wall.getComments", pager.params, "offset", fmt.Sprint(pager.offset)),
+			jsonUnmarshal[commentsResponse],
+		),
+		func(resp commentsResponse) f.Option[[]commentNode] {
+			pager.offset += uint(wallGetCommentsPageSize)
+			pager.total = f.Some(resp.Response.Count)
+			if len(resp.Response.Items) == 0 {
+				// no more comments even though count says otherwise, e.g. some were deleted meanwhile
+				pager.total = f.Some(pager.offset)
+			}
+
+			authors := make(map[OwnerID]Owner, len(resp.Response.Profiles)+len(resp.Response.Groups))
+			for _, profile := range resp.Response.Profiles {
+				authors[profile.ID.Owner()] = UserOwner(profile)
+			}
+			for _, group := range resp.Response.Groups {
+				authors[group.ID.Owner()] = GroupOwner(group)
+			}
+			nodes := make([]commentNode, 0, len(resp.Response.Items))
+			for _, item := range resp.Response.Items {
+				node := commentNode{comment: pager.comment(item, pager.parentID, authors)}
+				for _, reply := range item.Thread.Items {
+					node.replies = append(node.replies, pager.comment(reply, item.ID, authors))
+				}
+				nodes = append(nodes, node)
+			}
+			return f.Some(nodes)
+		},
+	)
+}
+
+func (pager *commentsPager) comment(item commentItem, parentID uint, authors map[OwnerID]Owner) Comment {
+	comment := item.Comment
+	comment.PostID = pager.postID
+	comment.ParentID = parentID
+	comment.Author = authorOf(authors, comment.FromID)
+	comment.Thread = CommentThread{Count: item.Thread.Count}
+	return comment
+}
+
+// authorOf finds author profile, falling back to profile with id only.
+func authorOf(authors map[OwnerID]Owner, id OwnerID) Owner {
+	if author, ok := authors[id]; ok {
+		return author
+	}
+	if id.IsGroup() {
+		return GroupOwner(Group{ID: id.GroupID()})
+	}
+	return UserOwner(User{ID: id.UserID()})
+}
+
+// commentsPager makes pager of top level comments of post if parentID is zero, otherwise of replies
+// in thread of comment starting from offset.
+func (client *VKClient) commentsPager(ctx context.Context, postID PostID, parentID, offset uint) *commentsPager {
+	params := MakeUrlValues(map[string]any{
+		"owner_id": postID.OwnerID,
+		"post_id":  postID.ID,
+		"extended": 1,
+		"fields":   client.fields("name", "screen_name"),
+		"count":    wallGetCommentsPageSize,
+		"sort":     "asc",
+	})
+	if parentID != 0 {
+		params.Set("comment_id", fmt.Sprint(parentID))
+	} else {
+		params.Set("thread_items_count", fmt.Sprint(threadItemsCount))
+	}
+	return &commentsPager{
+		ctx:      ctx,
+		client:   client,
+		postID:   postID,
+		parentID: parentID,
+		params:   params,
+		offset:   offset,
+		total:    f.None[uint](),
+	}
+}
+
+// commentStream streams top level comments, each followed by replies in its thread.
+// Replies sent along with top level comment are emitted first, the rest of thread is paged
+// only when stream reaches it.
+type commentStream struct {
+	client  *VKClient
+	ctx     context.Context
+	postID  PostID
+	top     ErrStream[commentNode]
+	replies []Comment
+	thread  ErrStream[commentNode]
+	err     error
+}
+
+func (xs *commentStream) Next() f.Option[Comment] {
+	if xs.err != nil {
+		return f.None[Comment]()
+	}
+	if len(xs.replies) > 0 {
+		reply := xs.replies[0]
+		xs.replies = xs.replies[1:]
+		return f.Some(reply)
+	}
+	if xs.thread != nil {
+		if reply := xs.thread.Next(); reply.IsSome() {
+			return f.Some(reply.Unwrap().comment)
+		}
+		if xs.err = xs.thread.Err(); xs.err != nil {
+			return f.None[Comment]()
+		}
+		xs.thread = nil
+	}
+	node := xs.top.Next()
+	if node.IsNone() {
+		xs.err = xs.top.Err()
+		return f.None[Comment]()
+	}
+	comment, replies := node.Unwrap().comment, node.Unwrap().replies
+	xs.replies = replies
+	if comment.Thread.Count > uint(len(replies)) {
+		xs.thread = getPaged[commentNode](xs.client.commentsPager(xs.ctx, xs.postID, comment.ID, uint(len(replies))))
+	}
+	return f.Some(comment)
+}
+
+func (xs *commentStream) Err() error {
+	return xs.err
+}
+
+// Comments streams comments of post from old to new. Every top level comment is followed by replies in its thread,
+// thread is fetched only when stream reaches it. Stream fails if some comments could not be fetched.
+func (client *VKClient) Comments(ctx context.Context, postID PostID) ErrStream[Comment] {
+	return &commentStream{
+		client: client,
+		ctx:    ctx,
+		postID: postID,
+		top:    getPaged[commentNode](client.commentsPager(ctx, postID, 0, 0)),
+	}
+}
+
+// GetComments gets stream of post commenters, each author is emitted once, as soon as their first comment is fetched.
+// Stream fails if some comments could not be fetched.
+func (client *VKClient) GetComments(ctx context.Context, postID PostID) ErrStream[Owner] {
+	comments := client.Comments(ctx, postID)
+	seen := f.NewSet[OwnerID]()
+	authors := s.MapFilter(comments, func(comment Comment) f.Option[Owner] {
+		// deleted comments have no author
+		if comment.FromID == 0 || seen.Contains(comment.FromID) {
+			return f.None[Owner]()
+		}
+		seen.Add(comment.FromID)
+		return f.Some(comment.Author)
+	})
+	return withErr(authors, comments.Err)
+}
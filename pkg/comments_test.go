@@ -0,0 +1,131 @@
+package vkutils_test
+
+import (
+	"context"
+	"testing"
+
+	s "github.com/rprtr258/go-flow/stream"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+// comments makes n comments by from with ids starting from firstID.
+func comments(firstID uint, n int, from vk.OwnerID) []vktest.Comment {
+	res := make([]vktest.Comment, n)
+	for i := range res {
+		res[i] = vktest.Comment{ID: firstID + uint(i), FromID: from, Date: firstID + uint(i)}
+	}
+	return res
+}
+
+func TestComments(t *testing.T) {
+	postID := vk.PostID{OwnerID: -5, ID: 1}
+	top := comments(1, 2, 1)
+	top[0].Replies = comments(10, 2, -5)
+	top[0].Replies[1].ReplyToComment = 10
+	srv := vktest.NewServer(vktest.Fixtures{
+		Users:    map[vk.UserID]vk.User{1: {ID: 1, FirstName: "Ann", SecondName: "A"}},
+		Groups:   map[vk.GroupID]vk.Group{5: {ID: 5, Name: "G"}},
+		Comments: map[vk.PostID][]vktest.Comment{postID: top},
+	})
+	defer srv.Close()
+
+	client := srv.Client()
+	stream := client.Comments(context.Background(), postID)
+	got := s.CollectToSlice[vk.Comment](stream)
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id, parent, replyTo uint
+		author              string
+	}{
+		{1, 0, 0, "Ann A"},
+		{10, 1, 0, "G"},
+		{11, 1, 10, "G"},
+		{2, 0, 0, "Ann A"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d comments, got %d", len(want), len(got))
+	}
+	for i, comment := range got {
+		w := want[i]
+		if comment.ID != w.id || comment.ParentID != w.parent || comment.ReplyToComment != w.replyTo || comment.Author.String() != w.author {
+			t.Errorf("expected comment %d with parent %d, reply to %d by %s, got comment %d with parent %d, reply to %d by %s",
+				w.id, w.parent, w.replyTo, w.author, comment.ID, comment.ParentID, comment.ReplyToComment, comment.Author)
+		}
+		if comment.PostID != postID {
+			t.Errorf("expected comment %d post %v, got %v", comment.ID, postID, comment.PostID)
+		}
+	}
+}
+
+func TestGetComments(t *testing.T) {
+	postID := vk.PostID{OwnerID: -5, ID: 1}
+	top := comments(1, 3, 1)
+	top[1].FromID = 2
+	top[2].Replies = comments(10, 2, 1)
+	srv := vktest.NewServer(vktest.Fixtures{
+		Comments: map[vk.PostID][]vktest.Comment{postID: top},
+	})
+	defer srv.Close()
+
+	client := srv.Client()
+	stream := client.GetComments(context.Background(), postID)
+	authors := s.CollectToSlice[vk.Owner](stream)
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(authors) != 2 || authors[0].ID() != 1 || authors[1].ID() != 2 {
+		t.Errorf("expected authors 1 and 2 once each, got %v", authors)
+	}
+}
+
+func TestCommentsThreadPaging(t *testing.T) {
+	postID := vk.PostID{OwnerID: -5, ID: 1}
+	// 150 top level comments, first of them has 25 replies, more than sent along with it
+	top := comments(1, 150, 1)
+	top[0].Replies = comments(1001, 25, -5)
+	srv := vktest.NewServer(vktest.Fixtures{
+		Users:    map[vk.UserID]vk.User{1: {ID: 1, FirstName: "Ann", SecondName: "A"}},
+		Groups:   map[vk.GroupID]vk.Group{5: {ID: 5, Name: "G"}},
+		Comments: map[vk.PostID][]vktest.Comment{postID: top},
+	})
+	defer srv.Close()
+
+	client := srv.Client()
+	stream := client.Comments(context.Background(), postID)
+	got := s.CollectToSlice[vk.Comment](stream)
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 175 {
+		t.Fatalf("expected 175 comments, got %d", len(got))
+	}
+
+	wantIDs := []uint{1}
+	for i := uint(1001); i <= 1025; i++ {
+		wantIDs = append(wantIDs, i)
+	}
+	for i := uint(2); i <= 150; i++ {
+		wantIDs = append(wantIDs, i)
+	}
+	for i, comment := range got {
+		if comment.ID != wantIDs[i] {
+			t.Fatalf("expected comment %d at %d, got %d", wantIDs[i], i, comment.ID)
+		}
+		wantParent, wantAuthor := uint(0), "Ann A"
+		if comment.ID > 1000 {
+			wantParent, wantAuthor = 1, "G"
+		}
+		if comment.ParentID != wantParent {
+			t.Errorf("expected comment %d parent %d, got %d", comment.ID, wantParent, comment.ParentID)
+		}
+		if comment.Author.String() != wantAuthor {
+			t.Errorf("expected comment %d author %s, got %s", comment.ID, wantAuthor, comment.Author)
+		}
+		if comment.PostID != postID {
+			t.Errorf("expected comment %d post %v, got %v", comment.ID, postID, comment.PostID)
+		}
+	}
+}
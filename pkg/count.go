@@ -1,40 +1,496 @@
 package vkutils
 
 import (
+	"context"
+	"fmt"
 	"sort"
+	"strings"
+	"sync"
 
 	f "github.com/rprtr258/go-flow/fun"
-	"github.com/rprtr258/go-flow/slice"
 	s "github.com/rprtr258/go-flow/stream"
 )
 
 type UserSets struct {
-	GroupMembers []UserID
+	GroupMembers []GroupID
 	Friends      []UserID
 	Followers    []UserID
 	Users        []UserID
 	Likers       []PostID
 	Commenters   []PostID
+	Managers     []GroupID
+	// MemberFriends are groups to take members of that are friends of token owner.
+	MemberFriends []GroupID
+	PollVoters    []PollAnswerID
+	// MutualFriends are pairs of users to take mutual friends of.
+	MutualFriends [][2]UserID
+	PhotoLikers   []PostID
+	VideoLikers   []PostID
+	CommentLikers []PostID
 }
 
-func MembershipCount(client VKClient, include UserSets) []f.Pair[User, uint] {
-	chans := s.Gather([]s.Stream[s.Stream[User]]{
-		s.Map(s.FromSlice(include.Friends), client.getFriends),
-		s.Map(s.FromSlice(include.GroupMembers), client.getGroupMembers),
-		s.Map(s.FromSlice(include.Followers), client.getFollowers),
-		s.Map(s.FromSlice(include.Users), func(userID UserID) s.Stream[User] {
-			return s.Once(User{
-				ID:         userID,
-				FirstName:  "UNKNOWN",
-				SecondName: "UNKNOWN",
+// SourceError tells that users of source were not fetched completely.
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (err SourceError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Source, err.Err)
+}
+
+func (err SourceError) Unwrap() error {
+	return err.Err
+}
+
+// Membership is user or group found in sources together with sources they are in.
+type Membership struct {
+	Owner Owner
+	// In tells whether owner is in source with same index in MembershipCountResult.Sources.
+	In []bool
+	// Count is number of sources owner is in.
+	Count uint
+	// Score is sum of weights of sources owner is in.
+	Score float64
+}
+
+// MembershipCountResult is memberships of users and groups over all sources and sources that were cut short by errors.
+type MembershipCountResult struct {
+	Sources    []Source
+	Counts     []Membership
+	Incomplete []SourceError
+}
+
+// SourceKind is kind of source of users.
+type SourceKind uint8
+
+const (
+	SourceFriends SourceKind = iota + 1
+	SourceGroupMembers
+	SourceFollowers
+	SourceUser
+	SourceLikers
+	SourceCommenters
+	SourceGroupManagers
+	SourceMemberFriends
+	SourcePollVoters
+	SourceMutualFriends
+	SourcePhotoLikers
+	SourceVideoLikers
+	SourceCommentLikers
+)
+
+// Source is single set of users, e.g. friends of some user or likers of some post.
+// Only field corresponding to Kind is set.
+type Source struct {
+	Kind SourceKind
+	User UserID
+	// Target is second user for mutual friends.
+	Target UserID
+	Group  GroupID
+	Post   PostID
+	// Item is owner and id of liked photo, video or comment.
+	Item PostID
+	Poll PollAnswerID
+}
+
+func (source Source) String() string {
+	switch source.Kind {
+	case SourceFriends:
+		return fmt.Sprintf("friends of %d", source.User)
+	case SourceGroupMembers:
+		return fmt.Sprintf("members of group %d", source.Group)
+	case SourceFollowers:
+		return fmt.Sprintf("followers of %d", source.User)
+	case SourceUser:
+		return fmt.Sprintf("user %d", source.User)
+	case SourceLikers:
+		return fmt.Sprintf("likers of post %d_%d", source.Post.OwnerID, source.Post.ID)
+	case SourceCommenters:
+		return fmt.Sprintf("commenters of post %d_%d", source.Post.OwnerID, source.Post.ID)
+	case SourceGroupManagers:
+		return fmt.Sprintf("managers of group %d", source.Group)
+	case SourceMemberFriends:
+		return fmt.Sprintf("friends among members of group %d", source.Group)
+	case SourcePollVoters:
+		if source.Poll.AnswerID == 0 {
+			return fmt.Sprintf("voters of poll %d_%d", source.Poll.OwnerID, source.Poll.PollID)
+		}
+		return fmt.Sprintf("voters for answer %d of poll %d_%d", source.Poll.AnswerID, source.Poll.OwnerID, source.Poll.PollID)
+	case SourceMutualFriends:
+		return fmt.Sprintf("mutual friends of %d and %d", source.User, source.Target)
+	case SourcePhotoLikers:
+		return fmt.Sprintf("likers of photo %d_%d", source.Item.OwnerID, source.Item.ID)
+	case SourceVideoLikers:
+		return fmt.Sprintf("likers of video %d_%d", source.Item.OwnerID, source.Item.ID)
+	case SourceCommentLikers:
+		return fmt.Sprintf("likers of comment %d_%d", source.Item.OwnerID, source.Item.ID)
+	default:
+		return "unknown source"
+	}
+}
+
+func (source Source) MarshalText() ([]byte, error) {
+	return []byte(source.String()), nil
+}
+
+// owners streams users and groups of source.
+func (source Source) owners(ctx context.Context, client VKClient) ErrStream[Owner] {
+	switch source.Kind {
+	case SourceFriends:
+		return mapErr(client.getFriends(ctx, source.User), UserOwner)
+	case SourceGroupMembers:
+		return mapErr(client.getGroupMembers(ctx, source.Group, ""), UserOwner)
+	case SourceFollowers:
+		return mapErr(client.getFollowers(ctx, source.User), UserOwner)
+	case SourceUser:
+		return withErr(s.Once(UserOwner(User{ID: source.User})), func() error { return nil })
+	case SourceLikers:
+		return client.getLikes(ctx, "post", source.Post)
+	case SourceCommenters:
+		return client.GetComments(ctx, source.Post)
+	case SourceGroupManagers:
+		return mapErr(client.getGroupMembers(ctx, source.Group, "managers"), UserOwner)
+	case SourceMemberFriends:
+		return mapErr(client.getGroupMembers(ctx, source.Group, "friends"), UserOwner)
+	case SourcePollVoters:
+		return mapErr(client.getPollVoters(ctx, source.Poll), UserOwner)
+	case SourceMutualFriends:
+		return mapErr(client.getMutualFriends(ctx, source.User, source.Target), UserOwner)
+	case SourcePhotoLikers:
+		return client.getLikes(ctx, "photo", source.Item)
+	case SourceVideoLikers:
+		return client.getLikes(ctx, "video", source.Item)
+	case SourceCommentLikers:
+		return client.getLikes(ctx, "comment", source.Item)
+	default:
+		return withErr(s.FromSlice([]Owner{}), func() error {
+			return fmt.Errorf("unknown source kind %d", source.Kind)
+		})
+	}
+}
+
+// Sources lists all sources of user sets.
+func (include UserSets) Sources() []Source {
+	sources := []Source{}
+	for _, userID := range include.Friends {
+		sources = append(sources, Source{Kind: SourceFriends, User: userID})
+	}
+	for _, groupID := range include.GroupMembers {
+		sources = append(sources, Source{Kind: SourceGroupMembers, Group: groupID})
+	}
+	for _, userID := range include.Followers {
+		sources = append(sources, Source{Kind: SourceFollowers, User: userID})
+	}
+	for _, userID := range include.Users {
+		sources = append(sources, Source{Kind: SourceUser, User: userID})
+	}
+	for _, postID := range include.Likers {
+		sources = append(sources, Source{Kind: SourceLikers, Post: postID})
+	}
+	for _, postID := range include.Commenters {
+		sources = append(sources, Source{Kind: SourceCommenters, Post: postID})
+	}
+	for _, groupID := range include.Managers {
+		sources = append(sources, Source{Kind: SourceGroupManagers, Group: groupID})
+	}
+	for _, groupID := range include.MemberFriends {
+		sources = append(sources, Source{Kind: SourceMemberFriends, Group: groupID})
+	}
+	for _, answerID := range include.PollVoters {
+		sources = append(sources, Source{Kind: SourcePollVoters, Poll: answerID})
+	}
+	for _, users := range include.MutualFriends {
+		sources = append(sources, Source{Kind: SourceMutualFriends, User: users[0], Target: users[1]})
+	}
+	for _, itemID := range include.PhotoLikers {
+		sources = append(sources, Source{Kind: SourcePhotoLikers, Item: itemID})
+	}
+	for _, itemID := range include.VideoLikers {
+		sources = append(sources, Source{Kind: SourceVideoLikers, Item: itemID})
+	}
+	for _, itemID := range include.CommentLikers {
+		sources = append(sources, Source{Kind: SourceCommentLikers, Item: itemID})
+	}
+	return sources
+}
+
+// sourceSets is ids of owners in every source and their profiles.
+type sourceSets struct {
+	ids      map[Source]f.Set[OwnerID]
+	profiles map[OwnerID]Owner
+	// complete is owners which profiles need not be fetched.
+	complete   f.Set[OwnerID]
+	incomplete []SourceError
+}
+
+// union returns ids of owners in any of sources.
+func (sets sourceSets) union() f.Set[OwnerID] {
+	res := f.NewSet[OwnerID]()
+	for _, ids := range sets.ids {
+		for id := range ids {
+			res.Add(id)
+		}
+	}
+	return res
+}
+
+// profile returns profile of owner, falling back to profile with id only.
+func (sets sourceSets) profile(id OwnerID) Owner {
+	return authorOf(sets.profiles, id)
+}
+
+// completeProfiles tells whether owners of source come with names and requested user fields.
+func (source Source) completeProfiles(client VKClient) bool {
+	switch source.Kind {
+	case SourceUser, SourceMutualFriends:
+		return false
+	case SourceLikers, SourcePhotoLikers, SourceVideoLikers, SourceCommentLikers:
+		// likers come with names only
+		return len(client.userFields) == 0
+	default:
+		return true
+	}
+}
+
+// hasName tells whether owner profile has name, e.g. comment authors may come without profile.
+func (owner Owner) hasName() bool {
+	if owner.IsGroup() {
+		return owner.Group.Name != ""
+	}
+	return owner.User.FirstName != "" || owner.User.SecondName != ""
+}
+
+// fetchSources fetches owners of all sources concurrently. Owners are identified by ids collected
+// while streaming, profiles are kept only for owners that can still match filter and the rest are
+// to be fetched with fillProfiles. Sources that failed have owners fetched before failure and are
+// listed as incomplete.
+func fetchSources(ctx context.Context, client VKClient, sources []Source, filter CountFilter) sourceSets {
+	res := sourceSets{
+		ids:        make(map[Source]f.Set[OwnerID], len(sources)),
+		profiles:   map[OwnerID]Owner{},
+		complete:   f.NewSet[OwnerID](),
+		incomplete: []SourceError{},
+	}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+		// counts is number of sources owner was seen in so far
+		counts  = map[OwnerID]uint{}
+		pending = uint(len(sources))
+	)
+	// canMatch tells whether count of owner can still match filter when pending sources are done.
+	canMatch := func(id OwnerID) bool {
+		count := counts[id]
+		return (filter.Max == 0 || count <= filter.Max) && count+pending >= filter.Min
+	}
+	errs := make([]error, len(sources))
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+			ids := f.NewSet[OwnerID]()
+			stream := source.owners(ctx, client)
+			s.ForEach[Owner](stream, func(owner Owner) {
+				id := owner.ID()
+				if ids.Contains(id) {
+					return
+				}
+				ids.Add(id)
+
+				mu.Lock()
+				defer mu.Unlock()
+				counts[id]++
+				if !canMatch(id) {
+					res.forget(id)
+				} else {
+					res.keep(owner, source.completeProfiles(client))
+				}
 			})
-		}),
-		s.Map(s.FromSlice(include.Likers), client.getLikes),
-		s.Map(s.FromSlice(include.Commenters), client.GetComments),
-	})
-	counters := s.Map(chans, s.CollectCounter[User])
-	resCounter := s.Reduce(f.NewCounter[User](), f.CounterPlus[User], counters)
-	res := slice.FromMap(resCounter)
-	sort.Slice(res, func(i, j int) bool { return res[i].Right > res[j].Right })
+
+			mu.Lock()
+			defer mu.Unlock()
+			res.ids[source] = ids
+			errs[i] = stream.Err()
+			pending--
+			for id := range res.profiles {
+				if !canMatch(id) {
+					res.forget(id)
+				}
+			}
+		}(i, source)
+	}
+	wg.Wait()
+
+	for i, source := range sources {
+		if errs[i] != nil {
+			res.incomplete = append(res.incomplete, SourceError{Source: source.String(), Err: errs[i]})
+		}
+	}
+	return res
+}
+
+// keep remembers profile of owner unless complete profile is already known.
+func (sets *sourceSets) keep(owner Owner, complete bool) {
+	id := owner.ID()
+	if sets.complete.Contains(id) || !owner.hasName() {
+		return
+	}
+	sets.profiles[id] = owner
+	// groups have no user fields
+	if complete || owner.IsGroup() {
+		sets.complete.Add(id)
+	}
+}
+
+// forget drops profile of owner that won't be in result.
+func (sets *sourceSets) forget(id OwnerID) {
+	delete(sets.profiles, id)
+	sets.complete.Remove(id)
+}
+
+// fillProfiles fetches profiles of owners that sources didn't provide fully.
+func (sets *sourceSets) fillProfiles(ctx context.Context, client VKClient, ids []OwnerID) {
+	missing := []OwnerID{}
+	for _, id := range ids {
+		if !sets.complete.Contains(id) {
+			missing = append(missing, id)
+		}
+	}
+	if err := client.fillProfiles(ctx, missing, sets.profiles); err != nil {
+		sets.incomplete = append(sets.incomplete, SourceError{Source: "profiles", Err: err})
+	}
+}
+
+// uniqueSources removes repeated sources keeping order.
+func uniqueSources(sources []Source) []Source {
+	res := []Source{}
+	seen := f.NewSet[Source]()
+	for _, source := range sources {
+		if !seen.Contains(source) {
+			seen.Add(source)
+			res = append(res, source)
+		}
+	}
 	return res
 }
+
+// CountOrder is order of owners with same score and number of sources.
+type CountOrder uint8
+
+const (
+	OrderByID CountOrder = iota
+	OrderByName
+)
+
+// CountFilter selects and orders owners of membership count.
+type CountFilter struct {
+	// Min and Max limit number of sources owner is in, zero Max means no limit.
+	Min, Max uint
+	// Top is max number of owners in result, zero means no limit.
+	Top uint
+	// Order is order of owners with same score and number of sources,
+	// owners with higher score go first, then owners in more sources.
+	Order CountOrder
+	// ExcludeDeactivated drops deleted and banned users and groups.
+	ExcludeDeactivated bool
+	// Weights are weights of sources in score owners are ordered by.
+	Weights Weights
+}
+
+// Weights are weights of sources in score of owner. Weight of source is looked up
+// by source itself, then by its kind, and is 1 if neither is set.
+type Weights struct {
+	Sources map[Source]float64
+	Kinds   map[SourceKind]float64
+}
+
+// EngagementWeights rank commenters above likers and likers above members, friends and followers.
+var EngagementWeights = Weights{Kinds: map[SourceKind]float64{
+	SourceCommenters:    3,
+	SourceLikers:        2,
+	SourcePhotoLikers:   2,
+	SourceVideoLikers:   2,
+	SourceCommentLikers: 2,
+}}
+
+// Of returns weight of source.
+func (weights Weights) Of(source Source) float64 {
+	if weight, ok := weights.Sources[source]; ok {
+		return weight
+	}
+	if weight, ok := weights.Kinds[source.Kind]; ok {
+		return weight
+	}
+	return 1
+}
+
+func (filter CountFilter) matches(count uint) bool {
+	return count >= filter.Min && (filter.Max == 0 || count <= filter.Max)
+}
+
+// less tells whether a goes before b.
+func (filter CountFilter) less(a, b Membership) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	if filter.Order == OrderByName {
+		if nameA, nameB := strings.ToLower(a.Owner.String()), strings.ToLower(b.Owner.String()); nameA != nameB {
+			return nameA < nameB
+		}
+	}
+	return a.Owner.ID() < b.Owner.ID()
+}
+
+// countMembership finds which of sources every member is in. Members are filtered by count before
+// their profiles are fetched, so that profiles of members not in result are never fetched.
+func countMembership(ctx context.Context, client VKClient, members f.Set[OwnerID], sources []Source, sets sourceSets, filter CountFilter) ([]Membership, []SourceError) {
+	res := []Membership{}
+	ids := []OwnerID{}
+	for member := range members {
+		membership := Membership{In: make([]bool, len(sources))}
+		for i, source := range sources {
+			sourceIDs := sets.ids[source]
+			if sourceIDs.Contains(member) {
+				membership.In[i] = true
+				membership.Count++
+				membership.Score += filter.Weights.Of(source)
+			}
+		}
+		if filter.matches(membership.Count) {
+			res = append(res, membership)
+			ids = append(ids, member)
+		}
+	}
+
+	sets.fillProfiles(ctx, client, ids)
+	filtered := res[:0]
+	for i, membership := range res {
+		membership.Owner = sets.profile(ids[i])
+		if !filter.ExcludeDeactivated || !membership.Owner.Deactivated() {
+			filtered = append(filtered, membership)
+		}
+	}
+	res = filtered
+	sort.Slice(res, func(i, j int) bool { return filter.less(res[i], res[j]) })
+	if filter.Top != 0 && uint(len(res)) > filter.Top {
+		res = res[:filter.Top]
+	}
+	return res, sets.incomplete
+}
+
+// MembershipCount counts in how many of given sources every user is. Sources that failed
+// are still counted with users fetched before failure and are listed as incomplete.
+func MembershipCount(ctx context.Context, client VKClient, include UserSets, filter CountFilter) MembershipCountResult {
+	sources := uniqueSources(include.Sources())
+	sets := fetchSources(ctx, client, sources, filter)
+	counts, incomplete := countMembership(ctx, client, sets.union(), sources, sets, filter)
+	return MembershipCountResult{
+		Sources:    sources,
+		Counts:     counts,
+		Incomplete: incomplete,
+	}
+}
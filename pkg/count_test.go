@@ -0,0 +1,205 @@
+package vkutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+// countFixtures are group 5 with members 1, 2 and deleted 3, friends of 1 and likers of post -5_1.
+var countFixtures = vktest.Fixtures{
+	Users: map[vk.UserID]vk.User{
+		1: {ID: 1, FirstName: "Ann", SecondName: "A"},
+		2: {ID: 2, FirstName: "Bob", SecondName: "B"},
+		3: {ID: 3, FirstName: "DELETED", Deactivated: "deleted"},
+		4: {ID: 4, FirstName: "Dan", SecondName: "D"},
+	},
+	Groups:       map[vk.GroupID]vk.Group{5: {ID: 5, Name: "G", ScreenName: "g"}},
+	GroupMembers: map[vk.GroupID][]vk.UserID{5: {1, 2, 3}},
+	Friends:      map[vk.UserID][]vk.UserID{1: {2, 3}},
+	Likes:        map[vk.PostID][]vk.OwnerID{{OwnerID: -5, ID: 1}: {2, -5}},
+}
+
+var countSets = vk.UserSets{
+	GroupMembers: []vk.GroupID{5},
+	Friends:      []vk.UserID{1},
+	Likers:       []vk.PostID{{OwnerID: -5, ID: 1}},
+}
+
+func ownerIDs(counts []vk.Membership) []vk.OwnerID {
+	res := make([]vk.OwnerID, len(counts))
+	for i, membership := range counts {
+		res[i] = membership.Owner.ID()
+	}
+	return res
+}
+
+func equalIDs(a, b []vk.OwnerID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMembershipCount(t *testing.T) {
+	srv := vktest.NewServer(countFixtures)
+	defer srv.Close()
+
+	res := vk.MembershipCount(context.Background(), srv.Client(), countSets, vk.CountFilter{})
+	if len(res.Incomplete) != 0 {
+		t.Fatalf("unexpected incomplete sources: %v", res.Incomplete)
+	}
+	if len(res.Sources) != 3 {
+		t.Fatalf("expected 3 sources, got %v", res.Sources)
+	}
+	if got, want := ownerIDs(res.Counts), []vk.OwnerID{2, 3, -5, 1}; !equalIDs(got, want) {
+		t.Fatalf("expected owners %v, got %v", want, got)
+	}
+	top := res.Counts[0]
+	if top.Count != 3 || top.Owner.String() != "Bob B" {
+		t.Errorf("expected Bob B in 3 sources, got %s in %d", top.Owner, top.Count)
+	}
+	for i, in := range top.In {
+		if !in {
+			t.Errorf("expected Bob B in source %s", res.Sources[i])
+		}
+	}
+	if group := res.Counts[2].Owner; !group.IsGroup() || group.String() != "G" {
+		t.Errorf("expected group G, got %s", group)
+	}
+}
+
+func TestMembershipCountFilter(t *testing.T) {
+	srv := vktest.NewServer(countFixtures)
+	defer srv.Close()
+
+	for _, test := range []struct {
+		name   string
+		filter vk.CountFilter
+		want   []vk.OwnerID
+	}{
+		{"min", vk.CountFilter{Min: 2}, []vk.OwnerID{2, 3}},
+		{"max", vk.CountFilter{Max: 1}, []vk.OwnerID{-5, 1}},
+		{"exactly", vk.CountFilter{Min: 2, Max: 2}, []vk.OwnerID{3}},
+		{"top", vk.CountFilter{Top: 1}, []vk.OwnerID{2}},
+		{"exclude deactivated", vk.CountFilter{ExcludeDeactivated: true}, []vk.OwnerID{2, -5, 1}},
+		{"order by name", vk.CountFilter{Max: 1, Order: vk.OrderByName}, []vk.OwnerID{1, -5}},
+		{"weights", vk.CountFilter{Weights: vk.Weights{Kinds: map[vk.SourceKind]float64{vk.SourceLikers: 5}}}, []vk.OwnerID{2, -5, 3, 1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			res := vk.MembershipCount(context.Background(), srv.Client(), countSets, test.filter)
+			if got := ownerIDs(res.Counts); !equalIDs(got, test.want) {
+				t.Errorf("expected owners %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestWeights(t *testing.T) {
+	likers := vk.Source{Kind: vk.SourceLikers, Post: vk.PostID{OwnerID: -5, ID: 1}}
+	weights := vk.Weights{
+		Sources: map[vk.Source]float64{likers: 5},
+		Kinds:   map[vk.SourceKind]float64{vk.SourceLikers: 2},
+	}
+	for _, test := range []struct {
+		source vk.Source
+		want   float64
+	}{
+		{likers, 5},
+		{vk.Source{Kind: vk.SourceLikers, Post: vk.PostID{OwnerID: -5, ID: 2}}, 2},
+		{vk.Source{Kind: vk.SourceGroupMembers, Group: 5}, 1},
+	} {
+		if got := weights.Of(test.source); got != test.want {
+			t.Errorf("%s: expected weight %v, got %v", test.source, test.want, got)
+		}
+	}
+	if commenters, likers := vk.EngagementWeights.Of(vk.Source{Kind: vk.SourceCommenters}), vk.EngagementWeights.Of(likers); commenters <= likers || likers <= 1 {
+		t.Errorf("expected commenters to outweigh likers and likers to outweigh members, got %v and %v", commenters, likers)
+	}
+}
+
+func TestMembershipCountFetchesMissingProfiles(t *testing.T) {
+	srv := vktest.NewServer(countFixtures)
+	defer srv.Close()
+
+	sets := vk.UserSets{GroupMembers: []vk.GroupID{5}, Users: []vk.UserID{1, 2}}
+	res := vk.MembershipCount(context.Background(), srv.Client(), sets, vk.CountFilter{Min: 2})
+	if got, want := ownerIDs(res.Counts), []vk.OwnerID{1, 2}; !equalIDs(got, want) {
+		t.Fatalf("expected owners %v, got %v", want, got)
+	}
+	if calls := srv.Calls("users.get"); calls != 0 {
+		t.Errorf("expected profiles of group members to be reused, got %d users.get calls", calls)
+	}
+
+	res = vk.MembershipCount(context.Background(), srv.Client(), vk.UserSets{Users: []vk.UserID{4}}, vk.CountFilter{})
+	if len(res.Counts) != 1 || res.Counts[0].Owner.String() != "Dan D" {
+		t.Fatalf("expected profile of Dan D, got %v", res.Counts)
+	}
+	if calls := srv.Calls("users.get"); calls != 1 {
+		t.Errorf("expected single users.get call, got %d", calls)
+	}
+}
+
+func TestMembershipCountIncomplete(t *testing.T) {
+	fixtures := countFixtures
+	fixtures.Errors = []vktest.ErrorRule{{Method: "friends.get", Code: vk.ErrAccessDenied}}
+	srv := vktest.NewServer(fixtures)
+	defer srv.Close()
+
+	res := vk.MembershipCount(context.Background(), srv.Client(), countSets, vk.CountFilter{})
+	if len(res.Incomplete) != 1 || res.Incomplete[0].Source != "friends of 1" {
+		t.Fatalf("expected friends of 1 to be incomplete, got %v", res.Incomplete)
+	}
+	if !errors.Is(res.Incomplete[0], vk.ErrAccessDenied) {
+		t.Errorf("expected access denied error, got %v", res.Incomplete[0].Err)
+	}
+	if got, want := ownerIDs(res.Counts), []vk.OwnerID{2, -5, 1, 3}; !equalIDs(got, want) {
+		t.Errorf("expected owners %v, got %v", want, got)
+	}
+}
+
+func TestMembershipCountMoreSources(t *testing.T) {
+	fixtures := countFixtures
+	fixtures.Friends = map[vk.UserID][]vk.UserID{1: {2, 3}, 4: {2, 3}}
+	fixtures.PollVoters = map[vk.PollAnswerID][]vk.UserID{
+		{OwnerID: -5, PollID: 7, AnswerID: 1}: {1, 2},
+		{OwnerID: -5, PollID: 7, AnswerID: 2}: {3},
+	}
+	fixtures.ItemLikes = map[string]map[vk.PostID][]vk.OwnerID{
+		"photo": {{OwnerID: 1, ID: 10}: {2, -5}},
+	}
+	srv := vktest.NewServer(fixtures)
+	defer srv.Close()
+
+	sets := vk.UserSets{
+		PollVoters:    []vk.PollAnswerID{{OwnerID: -5, PollID: 7}},
+		MutualFriends: [][2]vk.UserID{{1, 4}},
+		PhotoLikers:   []vk.PostID{{OwnerID: 1, ID: 10}},
+	}
+	res := vk.MembershipCount(context.Background(), srv.Client(), sets, vk.CountFilter{})
+	if len(res.Incomplete) != 0 {
+		t.Fatalf("unexpected incomplete sources: %v", res.Incomplete)
+	}
+	counts := map[vk.OwnerID]uint{}
+	for _, membership := range res.Counts {
+		counts[membership.Owner.ID()] = uint(membership.Count)
+	}
+	// voters of all poll answers, mutual friends of 1 and 4, likers of photo
+	want := map[vk.OwnerID]uint{1: 1, 2: 3, 3: 2, -5: 1}
+	if len(counts) != len(want) {
+		t.Fatalf("expected counts %v, got %v", want, counts)
+	}
+	for id, count := range want {
+		if counts[id] != count {
+			t.Errorf("expected %d in %d sources, got %d", id, count, counts[id])
+		}
+	}
+}
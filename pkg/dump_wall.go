@@ -1,16 +1,17 @@
 package vkutils
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/url"
 
 	f "github.com/rprtr258/go-flow/fun"
 	r "github.com/rprtr258/go-flow/result"
-	s "github.com/rprtr258/go-flow/stream"
 )
 
 type postsPager struct {
+	ctx    context.Context
 	client VKClient
 	offset uint
 	total  f.Option[uint]
@@ -22,18 +23,15 @@ func (pager *postsPager) NextPage() r.Result[f.Option[[]Post]] {
 		return r.Success(f.None[[]Post]())
 	}
 	wallPosts := r.TryRecover(
-		pager.client.getWallPosts(pager.params, "offset", fmt.Sprint(pager.offset)),
+		pager.client.getWallPosts(pager.ctx, pager.params, "offset", fmt.Sprint(pager.offset)),
 		func(err error) r.Result[WallPosts] {
-			if errMsg, ok := err.(ApiCallError); ok {
-				switch errMsg.vkError.Code {
-				case accessDenied, userWasDeletedOrBanned, profileIsPrivate:
-					return r.Success(WallPosts{Response: wallPostsResponse{
-						Count: 0,
-						Items: []Post{},
-					}})
-				}
+			var code ErrorCode
+			if errors.As(err, &code) && code.IsAccessError() {
+				return r.Success(WallPosts{Response: wallPostsResponse{
+					Count: 0,
+					Items: []Post{},
+				}})
 			}
-			log.Printf("Error getting posts: %T(%[1]v)\n", err)
 			return r.Err[WallPosts](err)
 		},
 	)
@@ -47,20 +45,16 @@ func (pager *postsPager) NextPage() r.Result[f.Option[[]Post]] {
 	)
 }
 
-// GetPosts gets posts stream from group.
-func GetPosts(client VKClient, groupName string) r.Result[s.Stream[Post]] {
-	return r.Map(
-		client.getGroupID(groupName),
-		func(groupID UserID) s.Stream[Post] {
-			return getPaged[Post](&postsPager{
-				client: client,
-				offset: 0,
-				total:  f.None[uint](),
-				params: MakeUrlValues(map[string]any{
-					"owner_id": groupID,
-					"count":    wallGetPageSize,
-				}),
-			})
-		},
-	)
+// GetPosts gets posts stream from user or group wall. Stream fails if some page of posts could not be fetched.
+func GetPosts(ctx context.Context, client VKClient, ownerID OwnerID) ErrStream[Post] {
+	return getPaged[Post](&postsPager{
+		ctx:    ctx,
+		client: client,
+		offset: 0,
+		total:  f.None[uint](),
+		params: MakeUrlValues(map[string]any{
+			"owner_id": ownerID,
+			"count":    wallGetPageSize,
+		}),
+	})
 }
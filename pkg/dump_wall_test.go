@@ -0,0 +1,66 @@
+package vkutils_test
+
+import (
+	"context"
+	"testing"
+
+	s "github.com/rprtr258/go-flow/stream"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+func TestGetPosts(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls: map[vk.OwnerID][]vk.Post{-5: wall(-5, 250)},
+	})
+	defer srv.Close()
+
+	stream := vk.GetPosts(context.Background(), srv.Client(), -5)
+	posts := s.CollectToSlice[vk.Post](stream)
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 250 {
+		t.Fatalf("expected 250 posts, got %d", len(posts))
+	}
+	for i, post := range posts {
+		if post.ID != uint(250-i) {
+			t.Fatalf("expected post %d at %d, got %d", 250-i, i, post.ID)
+		}
+	}
+	if calls := srv.Calls("wall.get"); calls != 3 {
+		t.Errorf("expected 3 pages, got %d wall.get calls", calls)
+	}
+}
+
+func TestGetPostsFailure(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls:  map[vk.OwnerID][]vk.Post{-5: wall(-5, 250)},
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrInvalidParams, Params: map[string]string{"offset": "100"}}},
+	})
+	defer srv.Close()
+
+	stream := vk.GetPosts(context.Background(), srv.Client(), -5)
+	posts := s.CollectToSlice[vk.Post](stream)
+	if len(posts) != 100 {
+		t.Errorf("expected posts of first page only, got %d", len(posts))
+	}
+	if stream.Err() == nil {
+		t.Error("expected stream to fail")
+	}
+}
+
+func TestGetPostsClosedWall(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrAccessDenied}},
+	})
+	defer srv.Close()
+
+	stream := vk.GetPosts(context.Background(), srv.Client(), 1)
+	if posts := s.CollectToSlice[vk.Post](stream); len(posts) != 0 {
+		t.Errorf("expected no posts, got %d", len(posts))
+	}
+	if err := stream.Err(); err != nil {
+		t.Errorf("expected closed wall to be empty, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package vkutils
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+)
+
+// ErrorCode is vk api error code. Known codes are errors themselves, so api call errors
+// can be checked with errors.Is(err, ErrAccessDenied) or errors.As(err, &code).
+type ErrorCode uint
+
+// vk api error codes, see https://dev.vk.com/reference/errors
+const (
+	ErrUnknown             ErrorCode = 1
+	ErrAppDisabled         ErrorCode = 2
+	ErrUnknownMethod       ErrorCode = 3
+	ErrAuthFailed          ErrorCode = 5
+	ErrTooManyRequests     ErrorCode = 6
+	ErrPermissionDenied    ErrorCode = 7
+	ErrInvalidRequest      ErrorCode = 8
+	ErrFloodControl        ErrorCode = 9
+	ErrInternal            ErrorCode = 10
+	ErrExecuteCompile      ErrorCode = 12
+	ErrExecuteRuntime      ErrorCode = 13
+	ErrCaptchaNeeded       ErrorCode = 14
+	ErrAccessDenied        ErrorCode = 15
+	ErrUserDeletedOrBanned ErrorCode = 18
+	ErrContentBlocked      ErrorCode = 19
+	ErrRateLimit           ErrorCode = 29
+	ErrProfileIsPrivate    ErrorCode = 30
+	ErrInvalidParams       ErrorCode = 100
+	ErrInvalidUserID       ErrorCode = 113
+	ErrGroupAccessDenied   ErrorCode = 203
+	ErrPostNotFound        ErrorCode = 210
+	ErrWallAccessDenied    ErrorCode = 212
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	ErrUnknown:             "unknown error",
+	ErrAppDisabled:         "application is disabled",
+	ErrUnknownMethod:       "unknown method",
+	ErrAuthFailed:          "user authorization failed",
+	ErrTooManyRequests:     "too many requests per second",
+	ErrPermissionDenied:    "permission to perform this action is denied",
+	ErrInvalidRequest:      "invalid request",
+	ErrFloodControl:        "flood control",
+	ErrInternal:            "internal server error",
+	ErrExecuteCompile:      "unable to compile execute code",
+	ErrExecuteRuntime:      "runtime error in execute code",
+	ErrCaptchaNeeded:       "captcha needed",
+	ErrAccessDenied:        "access denied",
+	ErrUserDeletedOrBanned: "user was deleted or banned",
+	ErrContentBlocked:      "content blocked",
+	ErrRateLimit:           "rate limit reached",
+	ErrProfileIsPrivate:    "profile is private",
+	ErrInvalidParams:       "one of the parameters specified was missing or invalid",
+	ErrInvalidUserID:       "invalid user id",
+	ErrGroupAccessDenied:   "access to group denied",
+	ErrPostNotFound:        "post not found",
+	ErrWallAccessDenied:    "access to wall's post denied",
+}
+
+func (code ErrorCode) Error() string {
+	if name, ok := errorCodeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("vk api error %d", uint(code))
+}
+
+// LogValue makes code logged as number.
+func (code ErrorCode) LogValue() slog.Value {
+	return slog.Uint64Value(uint64(code))
+}
+
+// IsAccessError checks whether code means that requested data is not available to token owner.
+func (code ErrorCode) IsAccessError() bool {
+	switch code {
+	case ErrPermissionDenied, ErrAccessDenied, ErrUserDeletedOrBanned, ErrContentBlocked,
+		ErrProfileIsPrivate, ErrGroupAccessDenied, ErrWallAccessDenied:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRateLimit checks whether code means that too many requests were sent.
+func (code ErrorCode) IsRateLimit() bool {
+	switch code {
+	case ErrTooManyRequests, ErrFloodControl, ErrRateLimit:
+		return true
+	default:
+		return false
+	}
+}
+
+// VkError is vk api error.
+type VkError struct {
+	Code    ErrorCode `json:"error_code"`
+	Message string    `json:"error_msg"`
+	// CaptchaSID and CaptchaImg are set for ErrCaptchaNeeded
+	CaptchaSID string `json:"captcha_sid,omitempty"`
+	CaptchaImg string `json:"captcha_img,omitempty"`
+}
+
+func (err VkError) Error() string {
+	return fmt.Sprintf("Error(%d) %s", err.Code, err.Message)
+}
+
+// Unwrap returns error code.
+func (err VkError) Unwrap() error {
+	return err.Code
+}
+
+type VkErrorResponse struct {
+	Err VkError `json:"error"`
+}
+
+// ApiCallError is vk api error returned by method call.
+type ApiCallError struct {
+	vkError VkError
+	method  string
+	params  url.Values
+}
+
+func (err ApiCallError) Error() string {
+	return fmt.Sprintf("error while %s(%v): %v", err.method, err.params, err.vkError)
+}
+
+// Unwrap returns vk api error, so error code can be checked with errors.Is.
+func (err ApiCallError) Unwrap() error {
+	return err.vkError
+}
+
+// Code returns vk api error code.
+func (err ApiCallError) Code() ErrorCode {
+	return err.vkError.Code
+}
+
+// Message returns vk api error message.
+func (err ApiCallError) Message() string {
+	return err.vkError.Message
+}
+
+// Method returns called api method.
+func (err ApiCallError) Method() string {
+	return err.method
+}
+
+// Params returns parameters api method was called with.
+func (err ApiCallError) Params() url.Values {
+	return err.params
+}
+
+// PostHiddenError is returned when post is deleted or not visible to token owner.
+type PostHiddenError struct {
+	PostID PostID
+}
+
+func (err PostHiddenError) Error() string {
+	return fmt.Sprintf("Post %d_%d is hidden", err.PostID.OwnerID, err.PostID.ID)
+}
+
+// ScreenNameNotFoundError is returned when no user, group or application has given screen name.
+type ScreenNameNotFoundError struct {
+	Name string
+}
+
+func (err ScreenNameNotFoundError) Error() string {
+	return fmt.Sprintf("screen name %q is not found", err.Name)
+}
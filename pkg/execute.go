@@ -0,0 +1,233 @@
+package vkutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/rprtr258/go-flow/result"
+)
+
+const (
+	// DefaultExecuteBatchSize is max number of api calls VK allows in single execute request.
+	DefaultExecuteBatchSize = 25
+	// how long to wait for other calls to join batch before sending it.
+	executeBatchWait = 20 * time.Millisecond
+)
+
+type executeResponse struct {
+	Response      []json.RawMessage `json:"response"`
+	ExecuteErrors []struct {
+		Method  string    `json:"method"`
+		Code    ErrorCode `json:"error_code"`
+		Message string    `json:"error_msg"`
+	} `json:"execute_errors"`
+}
+
+type batchCall struct {
+	ctx    context.Context
+	method string
+	params url.Values
+	done   chan r.Result[[]byte]
+}
+
+// batcher merges api calls made concurrently into execute requests.
+// Nil batcher sends every call as separate request.
+type batcher struct {
+	client VKClient
+	size   int
+
+	mu        sync.Mutex
+	queue     []*batchCall
+	scheduled bool
+}
+
+func newBatcher(client VKClient, size int) *batcher {
+	if size <= 1 {
+		return nil
+	}
+	return &batcher{
+		client: client,
+		size:   size,
+	}
+}
+
+// batchRequest is the same as apiRequest, but call may be sent in execute together with other calls.
+func (client *VKClient) batchRequest(ctx context.Context, method string, params url.Values, params2 ...string) r.Result[[]byte] {
+	params = cloneValues(params)
+	for i := 0; i < len(params2); i += 2 {
+		params.Set(params2[i], params2[i+1])
+	}
+	if client.batcher == nil || client.offline {
+		return client.apiRequest(ctx, method, params)
+	}
+	if client.cache != nil {
+		if cached := client.cache.Get(method, params, false); cached.IsSome() {
+			return client.apiRequest(ctx, method, params)
+		}
+	}
+	body := client.batcher.do(ctx, method, params)
+	if client.cache != nil && body.IsSuccess() {
+		if err := client.cache.Set(method, params, body.Unwrap()); err != nil {
+			client.logger.Error("error caching response", "method", method, "params", params, "error", err)
+		}
+	}
+	return body
+}
+
+func (b *batcher) do(ctx context.Context, method string, params url.Values) r.Result[[]byte] {
+	call := &batchCall{
+		ctx:    ctx,
+		method: method,
+		params: params,
+		done:   make(chan r.Result[[]byte], 1),
+	}
+
+	b.mu.Lock()
+	b.queue = append(b.queue, call)
+	if len(b.queue) >= b.size {
+		batch := b.queue[:b.size]
+		b.queue = b.queue[b.size:]
+		go b.send(batch)
+	} else if !b.scheduled {
+		b.scheduled = true
+		time.AfterFunc(executeBatchWait, b.flush)
+	}
+	b.mu.Unlock()
+
+	select {
+	case res := <-call.done:
+		return res
+	case <-ctx.Done():
+		return r.Err[[]byte](ctx.Err())
+	}
+}
+
+func (b *batcher) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scheduled = false
+	if len(b.queue) == 0 {
+		return
+	}
+	n := len(b.queue)
+	if n > b.size {
+		n = b.size
+	}
+	batch := b.queue[:n]
+	b.queue = b.queue[n:]
+	if len(b.queue) > 0 {
+		b.scheduled = true
+		time.AfterFunc(executeBatchWait, b.flush)
+	}
+	go b.send(batch)
+}
+
+// send sends batch in single execute request. Batch is sent with context of its first call
+// that is not done yet, calls with done context are dropped.
+func (b *batcher) send(batch []*batchCall) {
+	live := batch[:0]
+	for _, call := range batch {
+		if call.ctx.Err() == nil {
+			live = append(live, call)
+		}
+	}
+	switch len(live) {
+	case 0:
+		return
+	case 1:
+		call := live[0]
+		call.done <- b.client.apiRequest(call.ctx, call.method, call.params)
+		return
+	}
+
+	code := executeCode(live)
+	if code.IsErr() {
+		for _, call := range live {
+			call.done <- r.Err[[]byte](code.UnwrapErr())
+		}
+		return
+	}
+	resp := r.FlatMap(
+		b.client.apiRequest(live[0].ctx, "execute", url.Values{"code": {code.Unwrap()}}),
+		jsonUnmarshal[executeResponse],
+	)
+	if resp.IsErr() {
+		for _, call := range live {
+			call.done <- r.Err[[]byte](resp.UnwrapErr())
+		}
+		return
+	}
+	b.splitExecuteResponse(live, resp.Unwrap())
+}
+
+// executeCode makes VKScript code calling all methods and returning array of their results.
+func executeCode(batch []*batchCall) r.Result[string] {
+	calls := make([]string, 0, len(batch))
+	for _, call := range batch {
+		args := make(map[string]string, len(call.params))
+		for k := range call.params {
+			args[k] = call.params.Get(k)
+		}
+		argsJSON, err := json.Marshal(args)
+		if err != nil {
+			return r.Err[string](err)
+		}
+		calls = append(calls, fmt.Sprintf("API.%s(%s)", call.method, argsJSON))
+	}
+	return r.Success(fmt.Sprintf("return [%s];", strings.Join(calls, ",")))
+}
+
+// splitExecuteResponse sends every call its own result from execute response.
+// Failed calls have false as result and their errors are listed in execute_errors in the same order.
+// Calls failed with errors apiRequest can recover from are resent on their own, so that they are
+// retried, captcha is solved and token pool learns about token errors.
+func (b *batcher) splitExecuteResponse(batch []*batchCall, resp executeResponse) {
+	errorIndex := 0
+	for i, call := range batch {
+		if i >= len(resp.Response) {
+			call.done <- r.Err[[]byte](fmt.Errorf("%s(%v): no response in execute", call.method, call.params))
+			continue
+		}
+		result := resp.Response[i]
+		if string(result) == "false" {
+			vkError := VkError{Code: ErrUnknown, Message: "unknown execute error"}
+			if errorIndex < len(resp.ExecuteErrors) {
+				executeError := resp.ExecuteErrors[errorIndex]
+				vkError = VkError{Code: executeError.Code, Message: executeError.Message}
+				errorIndex++
+			}
+			if isRecoverable(vkError.Code) {
+				go func(call *batchCall) {
+					call.done <- b.client.apiRequest(call.ctx, call.method, call.params)
+				}(call)
+				continue
+			}
+			call.done <- r.Err[[]byte](ApiCallError{
+				vkError: vkError,
+				method:  call.method,
+				params:  call.params,
+			})
+			continue
+		}
+		call.done <- r.Success([]byte(fmt.Sprintf(`{"response":%s}`, result)))
+	}
+}
+
+// isRecoverable checks whether apiRequest may get result after error with given code
+// by retrying request, switching access token or solving captcha.
+func isRecoverable(code ErrorCode) bool {
+	return isRetryable(code) || code.IsRateLimit() || code == ErrAuthFailed || code == ErrCaptchaNeeded
+}
+
+func cloneValues(values url.Values) url.Values {
+	res := make(url.Values, len(values))
+	for k, v := range values {
+		res[k] = append([]string(nil), v...)
+	}
+	return res
+}
@@ -0,0 +1,93 @@
+package vkutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+// bigGroup makes fixtures with group 5 having n members, so that they are fetched in several pages.
+func bigGroup(n int) vktest.Fixtures {
+	members := make([]vk.UserID, n)
+	for i := range members {
+		members[i] = vk.UserID(i + 1)
+	}
+	return vktest.Fixtures{
+		Groups:       map[vk.GroupID]vk.Group{5: {ID: 5, Name: "G", ScreenName: "g"}},
+		GroupMembers: map[vk.GroupID][]vk.UserID{5: members},
+	}
+}
+
+var fastRetries = vk.WithRetryPolicy(vk.RetryPolicy{
+	MaxAttempts: 3,
+	InitialWait: time.Millisecond,
+	MaxWait:     time.Millisecond,
+	Multiplier:  2,
+})
+
+func TestExecuteBatching(t *testing.T) {
+	srv := vktest.NewServer(bigGroup(3500))
+	defer srv.Close()
+
+	res := vk.MembershipCount(context.Background(), srv.Client(), vk.UserSets{GroupMembers: []vk.GroupID{5}}, vk.CountFilter{})
+	if len(res.Incomplete) != 0 {
+		t.Fatalf("unexpected incomplete sources: %v", res.Incomplete)
+	}
+	if len(res.Counts) != 3500 {
+		t.Errorf("expected 3500 members, got %d", len(res.Counts))
+	}
+	// first page tells members count, other three pages are requested in single execute
+	if calls := srv.Calls("groups.getMembers"); calls != 4 {
+		t.Errorf("expected 4 pages, got %d", calls)
+	}
+	if calls := srv.Calls("execute"); calls != 1 {
+		t.Errorf("expected single execute, got %d", calls)
+	}
+}
+
+func TestExecuteRetriesFailedCall(t *testing.T) {
+	fixtures := bigGroup(3500)
+	fixtures.Errors = []vktest.ErrorRule{{
+		Method: "groups.getMembers",
+		Params: map[string]string{"offset": "2000"},
+		Code:   vk.ErrInternal,
+		Times:  1,
+	}}
+	srv := vktest.NewServer(fixtures)
+	defer srv.Close()
+
+	res := vk.MembershipCount(context.Background(), srv.Client(fastRetries), vk.UserSets{GroupMembers: []vk.GroupID{5}}, vk.CountFilter{})
+	if len(res.Incomplete) != 0 {
+		t.Fatalf("unexpected incomplete sources: %v", res.Incomplete)
+	}
+	if len(res.Counts) != 3500 {
+		t.Errorf("expected 3500 members, got %d", len(res.Counts))
+	}
+	// page failed inside execute is requested again on its own
+	if calls := srv.Calls("groups.getMembers"); calls != 5 {
+		t.Errorf("expected 5 page requests, got %d", calls)
+	}
+}
+
+func TestExecuteFailedCallNotResent(t *testing.T) {
+	fixtures := bigGroup(3500)
+	fixtures.Errors = []vktest.ErrorRule{{
+		Method: "groups.getMembers",
+		Params: map[string]string{"offset": "2000"},
+		Code:   vk.ErrAccessDenied,
+	}}
+	srv := vktest.NewServer(fixtures)
+	defer srv.Close()
+
+	res := vk.MembershipCount(context.Background(), srv.Client(fastRetries), vk.UserSets{GroupMembers: []vk.GroupID{5}}, vk.CountFilter{})
+	if len(res.Incomplete) != 1 || !errors.Is(res.Incomplete[0], vk.ErrAccessDenied) {
+		t.Fatalf("expected access denied error, got %v", res.Incomplete)
+	}
+	if calls := srv.Calls("groups.getMembers"); calls != 4 {
+		t.Errorf("expected 4 page requests, got %d", calls)
+	}
+}
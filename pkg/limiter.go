@@ -0,0 +1,123 @@
+package vkutils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRPS is VK api requests per second limit for user access token.
+	DefaultRPS = 3
+	// rate limiter won't slow down below this fraction of configured rate.
+	minRateFraction = 0.1
+	// rate is restored by this fraction of configured rate on each successful request.
+	rateRecoverFraction = 0.05
+)
+
+// rateLimiter is token bucket shared between all requests of client.
+// It slows down when api still responds with too many requests error
+// and restores configured rate gradually after successful requests.
+// Nil rateLimiter does not limit anything.
+type rateLimiter struct {
+	mu      sync.Mutex
+	maxRate float64
+	rate    float64
+	tokens  float64
+	last    time.Time
+}
+
+func newRateLimiter(rps float64) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		maxRate: rps,
+		rate:    rps,
+		tokens:  1,
+		last:    time.Now(),
+	}
+}
+
+func (l *rateLimiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > 1 {
+		l.tokens = 1
+	}
+	l.last = now
+}
+
+// Wait blocks until request can be sent or context is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	l.refill(time.Now())
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff halves current rate and drops accumulated tokens.
+func (l *rateLimiter) backoff() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.rate /= 2
+	if minRate := l.maxRate * minRateFraction; l.rate < minRate {
+		l.rate = minRate
+	}
+	if l.tokens > 0 {
+		l.tokens = 0
+	}
+}
+
+// restore increases current rate back towards configured one.
+func (l *rateLimiter) restore() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate < l.maxRate {
+		l.refill(time.Now())
+		l.rate += l.maxRate * rateRecoverFraction
+		if l.rate > l.maxRate {
+			l.rate = l.maxRate
+		}
+	}
+}
+
+// delay returns how long Wait would block now.
+func (l *rateLimiter) delay() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	if l.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
@@ -0,0 +1,81 @@
+package vkutils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := newRateLimiter(0)
+	if l != nil {
+		t.Fatal("expected zero rate to disable limiter")
+	}
+	for i := 0; i < 100; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if delay := l.delay(); delay != 0 {
+		t.Errorf("expected no delay, got %v", delay)
+	}
+	l.backoff()
+	l.restore()
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// first request is sent immediately, others wait 10ms each
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("expected requests to be spread over 50ms, took %v", elapsed)
+	}
+	if delay := l.delay(); delay <= 0 || delay > 10*time.Millisecond {
+		t.Errorf("expected delay up to 10ms, got %v", delay)
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	l := newRateLimiter(1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	// canceled wait gives its token back
+	if delay := l.delay(); delay > time.Second || delay < 900*time.Millisecond {
+		t.Errorf("expected delay about 1s, got %v", delay)
+	}
+}
+
+func TestRateLimiterBackoff(t *testing.T) {
+	l := newRateLimiter(10)
+	l.backoff()
+	if l.rate != 5 {
+		t.Errorf("expected rate to be halved to 5, got %v", l.rate)
+	}
+	for i := 0; i < 10; i++ {
+		l.backoff()
+	}
+	if l.rate != 10*minRateFraction {
+		t.Errorf("expected rate to stop at %v, got %v", 10*minRateFraction, l.rate)
+	}
+	l.restore()
+	if want := 10 * (minRateFraction + rateRecoverFraction); l.rate != want {
+		t.Errorf("expected rate to recover to %v, got %v", want, l.rate)
+	}
+	for i := 0; i < 100; i++ {
+		l.restore()
+	}
+	if l.rate != 10 {
+		t.Errorf("expected rate to recover up to 10, got %v", l.rate)
+	}
+}
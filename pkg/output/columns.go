@@ -0,0 +1,128 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	vk "github.com/rprtr258/vk-utils/pkg"
+)
+
+// ParseTemplate parses template of single value. Besides builtin functions, template can use
+// join to join slice elements, date to format unix time and json to encode value as json.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(template.FuncMap{
+		"join": join,
+		"date": formatDate,
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+}
+
+// join joins elements of slice printed with fmt.Sprint.
+func join(elems any, sep string) (string, error) {
+	v := reflect.ValueOf(elems)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected slice, got %T", elems)
+	}
+	strs := make([]string, v.Len())
+	for i := range strs {
+		strs[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(strs, sep), nil
+}
+
+// formatDate formats unix time as RFC 3339, zero time is empty.
+func formatDate(date uint) string {
+	if date == 0 {
+		return ""
+	}
+	return time.Unix(int64(date), 0).UTC().Format(time.RFC3339)
+}
+
+func number[N ~int | ~uint](n N) string {
+	return fmt.Sprint(n)
+}
+
+// OwnerColumns are columns of user or group profile followed by columns of extra user fields.
+func OwnerColumns(fields ...string) []Column[vk.Owner] {
+	columns := []Column[vk.Owner]{
+		{"id", func(owner vk.Owner) string { return number(owner.ID()) }},
+		{"type", func(owner vk.Owner) string {
+			if owner.IsGroup() {
+				return "group"
+			}
+			return "user"
+		}},
+		{"name", vk.Owner.String},
+		{"url", vk.Owner.URL},
+	}
+	for _, field := range fields {
+		field := field
+		columns = append(columns, Column[vk.Owner]{field, func(owner vk.Owner) string {
+			if owner.IsGroup() {
+				return ""
+			}
+			return owner.User.Field(field)
+		}})
+	}
+	return columns
+}
+
+// PostIDColumns are columns of post id.
+var PostIDColumns = []Column[vk.PostID]{
+	{"owner_id", func(id vk.PostID) string { return number(id.OwnerID) }},
+	{"id", func(id vk.PostID) string { return number(id.ID) }},
+	{"url", vk.PostID.URL},
+}
+
+// PostColumns are columns of post.
+var PostColumns = []Column[vk.Post]{
+	{"owner_id", func(post vk.Post) string { return number(post.Owner) }},
+	{"id", func(post vk.Post) string { return number(post.ID) }},
+	{"from_id", func(post vk.Post) string { return number(post.FromID) }},
+	{"date", func(post vk.Post) string { return formatDate(post.Date) }},
+	{"text", func(post vk.Post) string { return post.Text }},
+	{"attachments", func(post vk.Post) string { return attachmentURLs(post.Attachments) }},
+	{"likes", func(post vk.Post) string { return number(post.Likes.Count) }},
+	{"reposts", func(post vk.Post) string { return number(post.Reposts.Count) }},
+	{"comments", func(post vk.Post) string { return number(post.Comments.Count) }},
+	{"views", func(post vk.Post) string { return number(post.Views.Count) }},
+	{"repost_of", func(post vk.Post) string {
+		if len(post.CopyHistory) == 0 {
+			return ""
+		}
+		return post.CopyHistory[0].URL()
+	}},
+	{"url", vk.Post.URL},
+}
+
+// CommentColumns are columns of comment.
+var CommentColumns = []Column[vk.Comment]{
+	{"id", func(comment vk.Comment) string { return number(comment.ID) }},
+	{"parent_id", func(comment vk.Comment) string { return number(comment.ParentID) }},
+	{"reply_to_comment", func(comment vk.Comment) string { return number(comment.ReplyToComment) }},
+	{"from_id", func(comment vk.Comment) string { return number(comment.FromID) }},
+	{"author", func(comment vk.Comment) string { return comment.Author.String() }},
+	{"date", func(comment vk.Comment) string { return formatDate(comment.Date) }},
+	{"text", func(comment vk.Comment) string { return comment.Text }},
+	{"attachments", func(comment vk.Comment) string { return attachmentURLs(comment.Attachments) }},
+	{"likes", func(comment vk.Comment) string { return number(comment.Likes.Count) }},
+	{"url", vk.Comment.URL},
+}
+
+// attachmentURLs joins links to attachments with spaces, attachments without link are skipped.
+func attachmentURLs(attachments []vk.Attachment) string {
+	urls := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		if url := attachment.URL(); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return strings.Join(urls, " ")
+}
@@ -0,0 +1,230 @@
+// Package output encodes command results, e.g. users, posts or counts, in machine readable formats.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Format is output format.
+type Format string
+
+const (
+	// FormatText is human readable output specific to command, it is not handled by encoders.
+	FormatText     Format = "text"
+	FormatTable    Format = "table"
+	FormatJSON     Format = "json"
+	FormatNDJSON   Format = "ndjson"
+	FormatCSV      Format = "csv"
+	FormatTSV      Format = "tsv"
+	FormatTemplate Format = "template"
+)
+
+// Formats are all supported formats.
+var Formats = []Format{FormatText, FormatTable, FormatJSON, FormatNDJSON, FormatCSV, FormatTSV, FormatTemplate}
+
+// ParseFormat checks that format is supported.
+func ParseFormat(format string) (Format, error) {
+	for _, f := range Formats {
+		if Format(format) == f {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown output format %q, supported formats: %s", format, strings.Join(names, ", "))
+}
+
+// Column is named column of table, csv or tsv output.
+type Column[A any] struct {
+	Name  string
+	Value func(A) string
+}
+
+// MapColumns makes columns of A from columns of B.
+func MapColumns[A, B any](columns []Column[B], f func(A) B) []Column[A] {
+	res := make([]Column[A], len(columns))
+	for i, column := range columns {
+		value := column.Value
+		res[i] = Column[A]{
+			Name:  column.Name,
+			Value: func(a A) string { return value(f(a)) },
+		}
+	}
+	return res
+}
+
+// Encoder writes values one by one. Close must be called after last value.
+type Encoder[A any] interface {
+	Encode(A) error
+	Close() error
+}
+
+// New makes encoder of values in format. Columns are used by table, csv and tsv formats,
+// tmpl is executed for every value in template format.
+func New[A any](w io.Writer, format Format, columns []Column[A], tmpl *template.Template) (Encoder[A], error) {
+	switch format {
+	case FormatTable:
+		return newTableEncoder(w, columns), nil
+	case FormatJSON:
+		return &jsonEncoder[A]{w: w}, nil
+	case FormatNDJSON:
+		return ndjsonEncoder[A]{encoder: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return newCSVEncoder(w, ',', columns), nil
+	case FormatTSV:
+		return newCSVEncoder(w, '\t', columns), nil
+	case FormatTemplate:
+		if tmpl == nil {
+			return nil, errors.New("template output requires template")
+		}
+		return templateEncoder[A]{w: w, tmpl: tmpl}, nil
+	default:
+		return nil, fmt.Errorf("format %q has no encoder", format)
+	}
+}
+
+// tableEncoder prints aligned columns, it writes table only on Close since column widths
+// depend on all values.
+type tableEncoder[A any] struct {
+	w       *tabwriter.Writer
+	columns []Column[A]
+}
+
+func newTableEncoder[A any](w io.Writer, columns []Column[A]) *tableEncoder[A] {
+	enc := &tableEncoder[A]{
+		w:       tabwriter.NewWriter(w, 0, 0, 2, ' ', 0),
+		columns: columns,
+	}
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = strings.ToUpper(column.Name)
+	}
+	enc.writeRow(header)
+	return enc
+}
+
+// cellReplacer keeps every row on single line and cells separated.
+var cellReplacer = strings.NewReplacer("\n", " ", "\r", " ", "\t", " ")
+
+func (enc *tableEncoder[A]) writeRow(cells []string) {
+	for i, cell := range cells {
+		cells[i] = cellReplacer.Replace(cell)
+	}
+	fmt.Fprintln(enc.w, strings.Join(cells, "\t"))
+}
+
+func (enc *tableEncoder[A]) Encode(a A) error {
+	row := make([]string, len(enc.columns))
+	for i, column := range enc.columns {
+		row[i] = column.Value(a)
+	}
+	enc.writeRow(row)
+	return nil
+}
+
+func (enc *tableEncoder[A]) Close() error {
+	return enc.w.Flush()
+}
+
+// jsonEncoder writes json array, value by value.
+type jsonEncoder[A any] struct {
+	w       io.Writer
+	written bool
+}
+
+func (enc *jsonEncoder[A]) Encode(a A) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if !enc.written {
+		sep = "[\n"
+	}
+	enc.written = true
+	_, err = fmt.Fprintf(enc.w, "%s%s", sep, b)
+	return err
+}
+
+func (enc *jsonEncoder[A]) Close() error {
+	end := "\n]\n"
+	if !enc.written {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(enc.w, end)
+	return err
+}
+
+// ndjsonEncoder writes json value per line.
+type ndjsonEncoder[A any] struct {
+	encoder *json.Encoder
+}
+
+func (enc ndjsonEncoder[A]) Encode(a A) error {
+	return enc.encoder.Encode(a)
+}
+
+func (enc ndjsonEncoder[A]) Close() error {
+	return nil
+}
+
+// csvEncoder writes csv or tsv with header.
+type csvEncoder[A any] struct {
+	w       *csv.Writer
+	columns []Column[A]
+}
+
+func newCSVEncoder[A any](w io.Writer, comma rune, columns []Column[A]) *csvEncoder[A] {
+	enc := &csvEncoder[A]{
+		w:       csv.NewWriter(w),
+		columns: columns,
+	}
+	enc.w.Comma = comma
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	// error is kept by writer and returned on Close
+	_ = enc.w.Write(header)
+	return enc
+}
+
+func (enc *csvEncoder[A]) Encode(a A) error {
+	row := make([]string, len(enc.columns))
+	for i, column := range enc.columns {
+		row[i] = column.Value(a)
+	}
+	return enc.w.Write(row)
+}
+
+func (enc *csvEncoder[A]) Close() error {
+	enc.w.Flush()
+	return enc.w.Error()
+}
+
+// templateEncoder executes template for every value, each on its own line.
+type templateEncoder[A any] struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (enc templateEncoder[A]) Encode(a A) error {
+	if err := enc.tmpl.Execute(enc.w, a); err != nil {
+		return err
+	}
+	_, err := io.WriteString(enc.w, "\n")
+	return err
+}
+
+func (enc templateEncoder[A]) Close() error {
+	return nil
+}
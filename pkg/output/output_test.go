@@ -0,0 +1,123 @@
+package output
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	vk "github.com/rprtr258/vk-utils/pkg"
+)
+
+type point struct {
+	X    int    `json:"x"`
+	Name string `json:"name"`
+}
+
+var pointColumns = []Column[point]{
+	{"x", func(p point) string { return number(p.X) }},
+	{"name", func(p point) string { return p.Name }},
+}
+
+var points = []point{{1, "a"}, {22, "b\tc"}}
+
+// encode encodes values in format and returns output.
+func encode(t *testing.T, format Format, tmpl *template.Template, values []point) string {
+	t.Helper()
+	var sb strings.Builder
+	enc, err := New(&sb, format, pointColumns, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestEncoders(t *testing.T) {
+	tmpl, err := ParseTemplate(`{{.X}}={{.Name}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		format Format
+		values []point
+		want   string
+	}{
+		{FormatJSON, points, "[\n{\"x\":1,\"name\":\"a\"},\n{\"x\":22,\"name\":\"b\\tc\"}\n]\n"},
+		{FormatJSON, nil, "[]\n"},
+		{FormatNDJSON, points, "{\"x\":1,\"name\":\"a\"}\n{\"x\":22,\"name\":\"b\\tc\"}\n"},
+		{FormatNDJSON, nil, ""},
+		{FormatCSV, points, "x,name\n1,a\n22,b\tc\n"},
+		{FormatTSV, points, "x\tname\n1\ta\n22\t\"b\tc\"\n"},
+		{FormatTable, points, "X   NAME\n1   a\n22  b c\n"},
+		{FormatTemplate, points, "1=a\n22=b\tc\n"},
+	} {
+		if got := encode(t, test.format, tmpl, test.values); got != test.want {
+			t.Errorf("%s of %v: expected %q, got %q", test.format, test.values, test.want, got)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&strings.Builder{}, FormatTemplate, pointColumns, nil); err == nil {
+		t.Error("expected error of template format without template")
+	}
+	if _, err := New(&strings.Builder{}, FormatText, pointColumns, nil); err == nil {
+		t.Error("expected error of text format")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := ParseFormat("ndjson"); err != nil || format != FormatNDJSON {
+		t.Errorf("expected ndjson, got %q, error %v", format, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error of unknown format")
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	tmpl, err := ParseTemplate(`{{join .Names ", "}} {{json .Names}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, struct{ Names []string }{[]string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sb.String(), `a, b ["a","b"]`; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOwnerColumns(t *testing.T) {
+	var sb strings.Builder
+	enc := newCSVEncoder(&sb, ',', OwnerColumns("city"))
+	owners := []vk.Owner{
+		vk.UserOwner(vk.User{ID: 1, FirstName: "Ann", SecondName: "A", City: &vk.Location{ID: 1, Title: "Moscow"}}),
+		vk.UserOwner(vk.User{ID: 2, FirstName: "Bob"}),
+		vk.GroupOwner(vk.Group{ID: 5, Name: "G"}),
+	}
+	for _, owner := range owners {
+		if err := enc.Encode(owner); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `id,type,name,url,city
+1,user,Ann A,https://vk.com/id1,Moscow
+2,user,Bob,https://vk.com/id2,
+-5,group,G,https://vk.com/club5,
+`
+	if got := sb.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
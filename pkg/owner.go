@@ -0,0 +1,121 @@
+package vkutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// UserID is id of user.
+type UserID int
+
+// Owner returns id of user as wall owner.
+func (id UserID) Owner() OwnerID {
+	return OwnerID(id)
+}
+
+// GroupID is id of group, public page or event. Unlike api, it is always positive.
+type GroupID uint
+
+// Owner returns id of group as wall owner.
+func (id GroupID) Owner() OwnerID {
+	return -OwnerID(id)
+}
+
+// OwnerID is id of wall owner, post or comment author as api sends it:
+// user id if positive or negated group id if negative.
+type OwnerID int
+
+// IsGroup tells whether owner is group.
+func (id OwnerID) IsGroup() bool {
+	return id < 0
+}
+
+// UserID returns id of user, owner must not be group.
+func (id OwnerID) UserID() UserID {
+	return UserID(id)
+}
+
+// GroupID returns id of group, owner must be group.
+func (id OwnerID) GroupID() GroupID {
+	return GroupID(-id)
+}
+
+// URL returns link to user or group page.
+func (id OwnerID) URL() string {
+	if id.IsGroup() {
+		return fmt.Sprintf("https://vk.com/club%d", id.GroupID())
+	}
+	return fmt.Sprintf("https://vk.com/id%d", id.UserID())
+}
+
+// Group is group, public page or event profile.
+type Group struct {
+	ID          GroupID `json:"id"`
+	Name        string  `json:"name"`
+	ScreenName  string  `json:"screen_name,omitempty"`
+	Deactivated string  `json:"deactivated,omitempty"`
+}
+
+// Owner is profile of user or group, e.g. post or comment author. Only one of User and Group is set.
+type Owner struct {
+	User  User
+	Group Group
+}
+
+// UserOwner makes owner from user profile.
+func UserOwner(user User) Owner {
+	return Owner{User: user}
+}
+
+// GroupOwner makes owner from group profile.
+func GroupOwner(group Group) Owner {
+	return Owner{Group: group}
+}
+
+// IsGroup tells whether owner is group.
+func (owner Owner) IsGroup() bool {
+	return owner.Group.ID != 0
+}
+
+// ID returns id of owner.
+func (owner Owner) ID() OwnerID {
+	if owner.IsGroup() {
+		return owner.Group.ID.Owner()
+	}
+	return owner.User.ID.Owner()
+}
+
+// Deactivated tells whether user or group is deleted or banned.
+func (owner Owner) Deactivated() bool {
+	if owner.IsGroup() {
+		return owner.Group.Deactivated != ""
+	}
+	return owner.User.Deactivated != ""
+}
+
+// String returns user full name or group name.
+func (owner Owner) String() string {
+	if owner.IsGroup() {
+		return owner.Group.Name
+	}
+	return strings.TrimSpace(owner.User.FirstName + " " + owner.User.SecondName)
+}
+
+// URL returns link to user or group page.
+func (owner Owner) URL() string {
+	return owner.ID().URL()
+}
+
+func (owner Owner) MarshalJSON() ([]byte, error) {
+	if owner.IsGroup() {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Group
+		}{"group", owner.Group})
+	}
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		User
+	}{"user", owner.User})
+}
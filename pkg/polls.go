@@ -0,0 +1,109 @@
+package vkutils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	f "github.com/rprtr258/go-flow/fun"
+	r "github.com/rprtr258/go-flow/result"
+)
+
+// PollAnswerID is id of poll answer. Zero AnswerID stands for all answers of poll.
+type PollAnswerID struct {
+	OwnerID  OwnerID `json:"owner_id"`
+	PollID   uint    `json:"poll_id"`
+	AnswerID uint    `json:"answer_id,omitempty"`
+}
+
+type pollsGetByIDResponse struct {
+	Response Poll `json:"response"`
+}
+
+type pollsGetVotersResponse struct {
+	Response []struct {
+		AnswerID uint `json:"answer_id"`
+		Users    struct {
+			Count uint   `json:"count"`
+			Items []User `json:"items"`
+		} `json:"users"`
+	} `json:"response"`
+}
+
+// votersPager pages voters of poll answers one answer after another.
+type votersPager struct {
+	ctx     context.Context
+	client  *VKClient
+	params  url.Values
+	answers f.Option[[]uint]
+	offset  uint
+	total   f.Option[uint]
+}
+
+func (pager *votersPager) NextPage() r.Result[f.Option[[]User]] {
+	if pager.answers.IsNone() {
+		// all answers are requested, find them first
+		poll := r.FlatMap(
+			pager.client.apiRequest(pager.ctx, "polls.getById", pager.params),
+			jsonUnmarshal[pollsGetByIDResponse],
+		)
+		if poll.IsErr() {
+			return r.Err[f.Option[[]User]](poll.UnwrapErr())
+		}
+		answers := []uint{}
+		for _, answer := range poll.Unwrap().Response.Answers {
+			answers = append(answers, answer.ID)
+		}
+		pager.answers = f.Some(answers)
+	}
+	answers := pager.answers.Unwrap()
+	if pager.total.IsSome() && pager.offset >= pager.total.Unwrap() {
+		answers = answers[1:]
+		pager.answers, pager.offset, pager.total = f.Some(answers), 0, f.None[uint]()
+	}
+	if len(answers) == 0 {
+		return r.Success(f.None[[]User]())
+	}
+	return r.Map(
+		r.FlatMap(
+			pager.client.apiRequest(pager.ctx, "polls.getVoters", pager.params,
+				"answer_ids", fmt.Sprint(answers[0]),
+				"offset", fmt.Sprint(pager.offset),
+			),
+			jsonUnmarshal[pollsGetVotersResponse],
+		),
+		func(resp pollsGetVotersResponse) f.Option[[]User] {
+			pager.offset += uint(pollsGetVotersPageSize)
+			pager.total = f.Some(uint(0))
+			if len(resp.Response) == 0 {
+				return f.Some([]User{})
+			}
+			users := resp.Response[0].Users
+			if len(users.Items) > 0 {
+				pager.total = f.Some(users.Count)
+			}
+			return f.Some(users.Items)
+		},
+	)
+}
+
+// getPollVoters streams users who voted for poll answer, or for any answer if answer is not set.
+// Users of different answers may repeat in multiple answer polls.
+func (client *VKClient) getPollVoters(ctx context.Context, answerID PollAnswerID) ErrStream[User] {
+	answers := f.None[[]uint]()
+	if answerID.AnswerID != 0 {
+		answers = f.Some([]uint{answerID.AnswerID})
+	}
+	return getPaged[User](&votersPager{
+		ctx:    ctx,
+		client: client,
+		params: MakeUrlValues(map[string]any{
+			"owner_id": answerID.OwnerID,
+			"poll_id":  answerID.PollID,
+			"fields":   client.fields(),
+			"count":    pollsGetVotersPageSize,
+		}),
+		answers: answers,
+		total:   f.None[uint](),
+	})
+}
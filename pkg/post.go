@@ -0,0 +1,205 @@
+package vkutils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Bool is VK boolean, which api sends either as 0/1 or as true/false.
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "1", "true":
+		*b = true
+	case "0", "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid VK boolean: %s", data)
+	}
+	return nil
+}
+
+// Counter is number of likes, reposts, comments or views of post.
+type Counter struct {
+	Count uint `json:"count"`
+}
+
+// Post is post on some user or group wall.
+type Post struct {
+	Owner       OwnerID      `json:"owner_id"`
+	ID          uint         `json:"id"`
+	FromID      OwnerID      `json:"from_id,omitempty"`
+	SignerID    UserID       `json:"signer_id,omitempty"`
+	Date        uint         `json:"date"`
+	Edited      uint         `json:"edited,omitempty"`
+	PostType    string       `json:"post_type,omitempty"`
+	Text        string       `json:"text"`
+	IsPinned    Bool         `json:"is_pinned,omitempty"`
+	MarkedAsAds Bool         `json:"marked_as_ads,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Geo         *Geo         `json:"geo,omitempty"`
+	Likes       Counter      `json:"likes"`
+	Reposts     Counter      `json:"reposts"`
+	Comments    Counter      `json:"comments"`
+	Views       Counter      `json:"views"`
+	CopyHistory []Post       `json:"copy_history,omitempty"`
+}
+
+// PostID returns id of post.
+func (post Post) PostID() PostID {
+	return PostID{OwnerID: post.Owner, ID: post.ID}
+}
+
+// URL returns link to post.
+func (post Post) URL() string {
+	return post.PostID().URL()
+}
+
+// Geo is location post is attached to.
+type Geo struct {
+	Type        string `json:"type"`
+	Coordinates string `json:"coordinates"`
+	Place       *Place `json:"place,omitempty"`
+}
+
+type Place struct {
+	ID        int     `json:"id"`
+	Title     string  `json:"title"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Country   string  `json:"country,omitempty"`
+	City      string  `json:"city,omitempty"`
+}
+
+// Attachment is media attached to post. Only field corresponding to Type is set,
+// attachments of other types are kept as is in Raw.
+type Attachment struct {
+	Type  string          `json:"type"`
+	Photo *Photo          `json:"photo,omitempty"`
+	Video *Video          `json:"video,omitempty"`
+	Link  *Link           `json:"link,omitempty"`
+	Doc   *Doc            `json:"doc,omitempty"`
+	Poll  *Poll           `json:"poll,omitempty"`
+	Audio *Audio          `json:"audio,omitempty"`
+	Raw   json.RawMessage `json:"-"`
+}
+
+func (a *Attachment) UnmarshalJSON(data []byte) error {
+	type attachment Attachment
+	if err := json.Unmarshal(data, (*attachment)(a)); err != nil {
+		return err
+	}
+	a.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (a Attachment) MarshalJSON() ([]byte, error) {
+	type attachment Attachment
+	if a.Photo == nil && a.Video == nil && a.Link == nil && a.Doc == nil && a.Poll == nil && a.Audio == nil && a.Raw != nil {
+		return a.Raw, nil
+	}
+	return json.Marshal(attachment(a))
+}
+
+// URL returns link to attached media, empty if attachment type is not known.
+func (a Attachment) URL() string {
+	switch {
+	case a.Photo != nil:
+		return fmt.Sprintf("https://vk.com/photo%d_%d", a.Photo.OwnerID, a.Photo.ID)
+	case a.Video != nil:
+		return fmt.Sprintf("https://vk.com/video%d_%d", a.Video.OwnerID, a.Video.ID)
+	case a.Link != nil:
+		return a.Link.URL
+	case a.Doc != nil:
+		return a.Doc.URL
+	case a.Poll != nil:
+		return fmt.Sprintf("https://vk.com/poll%d_%d", a.Poll.OwnerID, a.Poll.ID)
+	case a.Audio != nil:
+		return fmt.Sprintf("https://vk.com/audio%d_%d", a.Audio.OwnerID, a.Audio.ID)
+	default:
+		return ""
+	}
+}
+
+type PhotoSize struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Width  uint   `json:"width"`
+	Height uint   `json:"height"`
+}
+
+type Photo struct {
+	ID      uint        `json:"id"`
+	OwnerID OwnerID     `json:"owner_id"`
+	AlbumID int         `json:"album_id"`
+	Date    uint        `json:"date"`
+	Text    string      `json:"text,omitempty"`
+	Sizes   []PhotoSize `json:"sizes,omitempty"`
+}
+
+// Largest returns biggest size of photo.
+func (photo Photo) Largest() PhotoSize {
+	var res PhotoSize
+	for _, size := range photo.Sizes {
+		if size.Width*size.Height >= res.Width*res.Height {
+			res = size
+		}
+	}
+	return res
+}
+
+type Video struct {
+	ID          uint    `json:"id"`
+	OwnerID     OwnerID `json:"owner_id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	Duration    uint    `json:"duration"`
+	Date        uint    `json:"date"`
+	Views       uint    `json:"views"`
+	Player      string  `json:"player,omitempty"`
+}
+
+type Link struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Caption     string `json:"caption,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Doc struct {
+	ID      uint    `json:"id"`
+	OwnerID OwnerID `json:"owner_id"`
+	Title   string  `json:"title"`
+	Size    uint    `json:"size"`
+	Ext     string  `json:"ext"`
+	URL     string  `json:"url"`
+	Date    uint    `json:"date"`
+}
+
+type PollAnswer struct {
+	ID    uint    `json:"id"`
+	Text  string  `json:"text"`
+	Votes uint    `json:"votes"`
+	Rate  float64 `json:"rate"`
+}
+
+type Poll struct {
+	ID        uint         `json:"id"`
+	OwnerID   OwnerID      `json:"owner_id"`
+	Question  string       `json:"question"`
+	Votes     uint         `json:"votes"`
+	Answers   []PollAnswer `json:"answers"`
+	Multiple  bool         `json:"multiple"`
+	Anonymous bool         `json:"anonymous"`
+	EndDate   uint         `json:"end_date,omitempty"`
+}
+
+type Audio struct {
+	ID       uint    `json:"id"`
+	OwnerID  OwnerID `json:"owner_id"`
+	Artist   string  `json:"artist"`
+	Title    string  `json:"title"`
+	Duration uint    `json:"duration"`
+	URL      string  `json:"url,omitempty"`
+}
@@ -0,0 +1,109 @@
+package vkutils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	r "github.com/rprtr258/go-flow/result"
+)
+
+// usersGetBatchSize and groupsGetByIDBatchSize are how many ids are sent in single request.
+// They are below api limits to keep request urls short.
+const (
+	usersGetBatchSize      = 500
+	groupsGetByIDBatchSize = 500
+)
+
+type usersGetResponse struct {
+	Response []User `json:"response"`
+}
+
+type groupsGetByIDResponse struct {
+	Response []Group `json:"response"`
+}
+
+// getByIDs fetches objects by ids in batches of batchSize ids, ids are passed in param.
+func getByIDs[ID any, A any](
+	ctx context.Context,
+	client *VKClient,
+	method string,
+	params url.Values,
+	param string,
+	ids []ID,
+	batchSize int,
+	items func([]byte) r.Result[[]A],
+) r.Result[[]A] {
+	res := make([]A, 0, len(ids))
+	for len(ids) > 0 {
+		batch := ids[:min(batchSize, len(ids))]
+		ids = ids[len(batch):]
+		strIDs := make([]string, len(batch))
+		for i, id := range batch {
+			strIDs[i] = fmt.Sprint(id)
+		}
+		page := r.FlatMap(client.apiRequest(ctx, method, params, param, strings.Join(strIDs, ",")), items)
+		if page.IsErr() {
+			return r.Err[[]A](page.UnwrapErr())
+		}
+		res = append(res, page.Unwrap()...)
+	}
+	return r.Success(res)
+}
+
+// getUsers fetches profiles of users. Users that don't exist are skipped.
+func (client *VKClient) getUsers(ctx context.Context, userIDs []UserID) r.Result[[]User] {
+	return getByIDs(ctx, client, "users.get", MakeUrlValues(map[string]any{
+		"fields": client.fields(),
+	}), "user_ids", userIDs, usersGetBatchSize, func(body []byte) r.Result[[]User] {
+		return r.Map(jsonUnmarshal[usersGetResponse](body), func(resp usersGetResponse) []User {
+			return resp.Response
+		})
+	})
+}
+
+// getGroups fetches profiles of groups.
+func (client *VKClient) getGroups(ctx context.Context, groupIDs []GroupID) r.Result[[]Group] {
+	return getByIDs(ctx, client, "groups.getById", url.Values{}, "group_ids", groupIDs, groupsGetByIDBatchSize, func(body []byte) r.Result[[]Group] {
+		return r.Map(jsonUnmarshal[groupsGetByIDResponse](body), func(resp groupsGetByIDResponse) []Group {
+			return resp.Response
+		})
+	})
+}
+
+// fillProfiles fetches profiles of owners by ids and puts them into profiles.
+// Users are put even if groups failed and vice versa, errors of failed requests are returned.
+func (client *VKClient) fillProfiles(ctx context.Context, ids []OwnerID, profiles map[OwnerID]Owner) error {
+	userIDs, groupIDs := []UserID{}, []GroupID{}
+	for _, id := range ids {
+		if id.IsGroup() {
+			groupIDs = append(groupIDs, id.GroupID())
+		} else {
+			userIDs = append(userIDs, id.UserID())
+		}
+	}
+
+	var errs errorList
+	if len(userIDs) > 0 {
+		users := client.getUsers(ctx, userIDs)
+		if users.IsErr() {
+			errs.add(users.UnwrapErr())
+		} else {
+			for _, user := range users.Unwrap() {
+				profiles[user.ID.Owner()] = UserOwner(user)
+			}
+		}
+	}
+	if len(groupIDs) > 0 {
+		groups := client.getGroups(ctx, groupIDs)
+		if groups.IsErr() {
+			errs.add(groups.UnwrapErr())
+		} else {
+			for _, group := range groups.Unwrap() {
+				profiles[group.ID.Owner()] = GroupOwner(group)
+			}
+		}
+	}
+	return errs.Err()
+}
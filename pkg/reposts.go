@@ -1,8 +1,10 @@
 package vkutils
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net/url"
 
 	f "github.com/rprtr258/go-flow/fun"
 	r "github.com/rprtr258/go-flow/result"
@@ -13,126 +15,165 @@ type PageSize uint
 
 const (
 	wallGetPageSize         = PageSize(100)
-	userCheckRepostsThreads = 10
+	userCheckRepostsThreads = DefaultExecuteBatchSize
 )
 
-// Returns either found (or not found) repost's post id
-func findRepost(client VKClient, userID UserID, postID PostID, postDate uint) f.Option[uint] {
+// getCandidateWallPosts gets page of wall posts of repost candidate. Closed or deleted walls have no posts.
+func getCandidateWallPosts(ctx context.Context, client VKClient, params url.Values, offset uint) r.Result[WallPosts] {
+	return r.TryRecover(
+		client.getWallPosts(ctx, params, "offset", fmt.Sprint(offset)),
+		func(err error) r.Result[WallPosts] {
+			var code ErrorCode
+			if errors.As(err, &code) && code.IsAccessError() {
+				return r.Success(WallPosts{Response: wallPostsResponse{Items: []Post{}}})
+			}
+			return r.Err[WallPosts](err)
+		},
+	)
+}
+
+// Returns either found (or not found) repost's post id, fails if user or group wall could not be searched.
+func findRepost(ctx context.Context, client VKClient, ownerID OwnerID, postID PostID, postDate uint) r.Result[f.Option[uint]] {
 	params := MakeUrlValues(map[string]any{
-		"owner_id": userID,
+		"owner_id": ownerID,
 		"count":    wallGetPageSize,
 	})
-	w0Result := client.getWallPosts(params, "offset", "0")
-	if w0Result.IsErr() || w0Result.Unwrap().Response.Count == 0 {
-		return f.None[uint]()
+	w0Result := getCandidateWallPosts(ctx, client, params, 0)
+	if w0Result.IsErr() {
+		return r.Err[f.Option[uint]](w0Result.UnwrapErr())
+	}
+	if w0Result.Unwrap().Response.Count == 0 {
+		return r.Success(f.None[uint]())
 	}
 	w0 := w0Result.Unwrap()
 	isRepostPredicate := func(post Post) bool {
 		copyHistory := post.CopyHistory
-		return len(copyHistory) != 0 && copyHistory[0].ID == postID.ID && copyHistory[0].OwnerID == postID.OwnerID
+		return len(copyHistory) != 0 && copyHistory[0].PostID() == postID
 	}
 	if len(w0.Response.Items) == 0 {
-		return f.None[uint]()
+		return r.Success(f.None[uint]())
 	}
 	if isRepostPredicate(w0.Response.Items[0]) {
-		return f.Some(w0.Response.Items[0].ID)
+		return r.Success(f.Some(w0.Response.Items[0].ID))
 	}
 	if w0.Response.Items[len(w0.Response.Items)-1].Date <= postDate {
 		for _, post := range w0.Response.Items[1:] {
 			if isRepostPredicate(post) {
-				return f.Some(post.ID)
+				return r.Success(f.Some(post.ID))
 			}
 		}
-		return f.None[uint]()
+		return r.Success(f.None[uint]())
 	}
-	log.Printf("SEARCHING REPOST IN USER %d WITH %d ENTRIES\n", userID, w0.Response.Count)
+	client.logger.Debug("searching repost in wall", "owner_id", ownerID, "posts", w0.Response.Count)
 	l := uint(1)
-	r := (w0.Response.Count + uint(wallGetPageSize) - 1) / uint(wallGetPageSize)
-	for r-l > 1 {
-		m := (l + r) / 2
-		w0Result = client.getWallPosts(params, "offset", fmt.Sprint(m*uint(wallGetPageSize)))
-		if w0Result.IsErr() || w0Result.Unwrap().Response.Count == 0 {
-			return f.None[uint]()
+	h := (w0.Response.Count + uint(wallGetPageSize) - 1) / uint(wallGetPageSize)
+	for h-l > 1 {
+		m := (l + h) / 2
+		w0Result = getCandidateWallPosts(ctx, client, params, m*uint(wallGetPageSize))
+		if w0Result.IsErr() {
+			return r.Err[f.Option[uint]](w0Result.UnwrapErr())
+		}
+		if w0Result.Unwrap().Response.Count == 0 {
+			return r.Success(f.None[uint]())
 		}
 		w0 = w0Result.Unwrap()
 		if w0.Response.Items[0].Date >= postDate && w0.Response.Items[len(w0.Response.Items)-1].Date <= postDate {
 			for _, post := range w0.Response.Items {
 				if isRepostPredicate(post) {
-					return f.Some(post.ID)
+					return r.Success(f.Some(post.ID))
 				}
 			}
 			l--
 			break
 		} else if w0.Response.Items[0].Date < postDate {
-			r = m
+			h = m
 		} else {
 			l = m
 		}
 	}
-	for l > 0 {
-		w0Result = client.getWallPosts(params, "offset", fmt.Sprint(l*uint(wallGetPageSize)))
-		if w0Result.IsErr() || w0Result.Unwrap().Response.Count == 0 {
-			return f.None[uint]()
+	for l > 0 && ctx.Err() == nil {
+		w0Result = getCandidateWallPosts(ctx, client, params, l*uint(wallGetPageSize))
+		if w0Result.IsErr() {
+			return r.Err[f.Option[uint]](w0Result.UnwrapErr())
+		}
+		if w0Result.Unwrap().Response.Count == 0 {
+			return r.Success(f.None[uint]())
 		}
 		if w0.Response.Items[0].Date-postDate > client.RepostSearchLimit {
-			return f.None[uint]()
+			return r.Success(f.None[uint]())
 		}
 		w0 = w0Result.Unwrap()
 		for _, post := range w0.Response.Items {
 			if isRepostPredicate(post) {
-				return f.Some(post.ID)
+				return r.Success(f.Some(post.ID))
 			}
 		}
 		l--
 	}
-	return f.None[uint]()
+	return r.Success(f.None[uint]())
 }
 
-func userInfoToUserID(info User) UserID {
-	return info.ID
+func userToOwnerID(user User) OwnerID {
+	return user.ID.Owner()
 }
 
-func getPotentialUserIDs(client VKClient, postID PostID) s.Stream[UserID] {
+// getPotentialOwnerIDs returns users and groups that might have reposted post.
+func getPotentialOwnerIDs(ctx context.Context, client VKClient, postID PostID) ErrStream[OwnerID] {
 	// scan commenters
-	commenters := s.Map(client.GetComments(postID), userInfoToUserID)
+	commenters := mapErr(client.GetComments(ctx, postID), Owner.ID)
 
 	// scan likers
-	likers := s.Map(client.getLikes(postID), userInfoToUserID)
+	likers := mapErr(client.getLikes(ctx, "post", postID), Owner.ID)
 
 	// scan group members/friends of post owner
-	var potentialUserIDs s.Stream[UserID]
-	if postID.OwnerID < 0 { // owner is group
-		potentialUserIDs = s.Map(client.getGroupMembers(postID.OwnerID), userInfoToUserID)
-	} else { // owner is user
-		potentialUserIDs = s.Map(client.getFriends(postID.OwnerID), userInfoToUserID)
+	var potentialUserIDs ErrStream[OwnerID]
+	if postID.OwnerID.IsGroup() {
+		potentialUserIDs = mapErr(client.getGroupMembers(ctx, postID.OwnerID.GroupID(), ""), userToOwnerID)
+	} else {
+		potentialUserIDs = mapErr(client.getFriends(ctx, postID.OwnerID.UserID()), userToOwnerID)
 	}
 
-	return s.Gather([]s.Stream[UserID]{commenters, likers, potentialUserIDs})
+	return gatherErr([]ErrStream[OwnerID]{commenters, likers, potentialUserIDs})
 }
 
-func getCheckedIDs(client VKClient, postID PostID, postDate uint, userIDs s.Stream[UserID]) s.Stream[PostID] {
-	findRepost := func(userID UserID) f.Option[PostID] {
+func getCheckedIDs(ctx context.Context, client VKClient, postID PostID, postDate uint, ownerIDs ErrStream[OwnerID]) ErrStream[PostID] {
+	var errs errorList
+	findRepost := func(ownerID OwnerID) f.Option[PostID] {
+		if ctx.Err() != nil {
+			return f.None[PostID]()
+		}
+		repost := findRepost(ctx, client, ownerID, postID, postDate)
+		if repost.IsErr() {
+			errs.add(fmt.Errorf("search repost on wall of %d: %w", ownerID, repost.UnwrapErr()))
+			return f.None[PostID]()
+		}
 		return f.Map(
-			findRepost(client, userID, postID, postDate),
+			repost.Unwrap(),
 			func(postID uint) PostID {
-				return PostID{userID, postID}
+				return PostID{ownerID, postID}
 			},
 		)
 	}
-	return s.Gather(s.CollectToSlice(s.Map(
-		s.FromSlice(s.Scatter(userIDs, userCheckRepostsThreads)),
-		func(userIDs s.Stream[UserID]) s.Stream[PostID] {
-			return s.MapFilter(userIDs, findRepost)
+	reposts := s.Gather(s.CollectToSlice(s.Map(
+		s.FromSlice(s.Scatter[OwnerID](ownerIDs, userCheckRepostsThreads)),
+		func(ownerIDs s.Stream[OwnerID]) s.Stream[PostID] {
+			return s.MapFilter(ownerIDs, findRepost)
 		},
 	)))
+	return withErr(reposts, func() error {
+		return errors.Join(ownerIDs.Err(), errs.Err())
+	})
 }
 
-func GetReposters(client VKClient, postID PostID) r.Result[s.Stream[PostID]] {
+// GetReposters finds reposts of given post among commenters, likers and owner's group members or friends.
+// Stream fails if some of candidates could not be fetched or checked.
+func GetReposters(ctx context.Context, client VKClient, postID PostID) r.Result[ErrStream[PostID]] {
 	return r.Map(
-		client.getPostTime(postID),
-		func(postDate uint) s.Stream[PostID] {
-			uniqueIDs := s.Unique(getPotentialUserIDs(client, postID))
-			return getCheckedIDs(client, postID, postDate, uniqueIDs)
+		client.getPostTime(ctx, postID),
+		func(postDate uint) ErrStream[PostID] {
+			candidates := getPotentialOwnerIDs(ctx, client, postID)
+			uniqueIDs := withErr(s.Unique[OwnerID](candidates), candidates.Err)
+			return getCheckedIDs(ctx, client, postID, postDate, uniqueIDs)
 		},
 	)
 }
@@ -0,0 +1,65 @@
+package vkutils_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	s "github.com/rprtr258/go-flow/stream"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+func TestGetReposters(t *testing.T) {
+	post := vk.Post{Owner: -5, ID: 1, Date: 100}
+	repost := func(ownerID vk.OwnerID, id, date uint) vk.Post {
+		return vk.Post{Owner: ownerID, ID: id, Date: date, CopyHistory: []vk.Post{post}}
+	}
+	srv := vktest.NewServer(vktest.Fixtures{
+		Groups:       map[vk.GroupID]vk.Group{5: {ID: 5, Name: "G"}},
+		GroupMembers: map[vk.GroupID][]vk.UserID{5: {1, 2}},
+		Likes:        map[vk.PostID][]vk.OwnerID{post.PostID(): {3}},
+		Comments:     map[vk.PostID][]vktest.Comment{post.PostID(): {{ID: 1, FromID: 4}}},
+		Walls: map[vk.OwnerID][]vk.Post{
+			-5: {post},
+			// member reposted post last
+			1: {repost(1, 7, 150), {Owner: 1, ID: 6, Date: 50}},
+			// member didn't repost post
+			2: {{Owner: 2, ID: 3, Date: 200}},
+			// liker reposted post before other posts
+			3: {{Owner: 3, ID: 9, Date: 300}, repost(3, 8, 120), {Owner: 3, ID: 5, Date: 10}},
+			// commenter reposted post
+			4: {repost(4, 2, 110)},
+		},
+	})
+	defer srv.Close()
+
+	res := vk.GetReposters(context.Background(), srv.Client(), post.PostID())
+	if res.IsErr() {
+		t.Fatal(res.UnwrapErr())
+	}
+	stream := res.Unwrap()
+	reposts := s.CollectToSlice[vk.PostID](stream)
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(reposts, func(i, j int) bool { return reposts[i].OwnerID < reposts[j].OwnerID })
+	want := []vk.PostID{{OwnerID: 1, ID: 7}, {OwnerID: 3, ID: 8}, {OwnerID: 4, ID: 2}}
+	if len(reposts) != len(want) {
+		t.Fatalf("expected reposts %v, got %v", want, reposts)
+	}
+	for i := range want {
+		if reposts[i] != want[i] {
+			t.Fatalf("expected reposts %v, got %v", want, reposts)
+		}
+	}
+}
+
+func TestGetRepostersHiddenPost(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{})
+	defer srv.Close()
+
+	if res := vk.GetReposters(context.Background(), srv.Client(), vk.PostID{OwnerID: -5, ID: 1}); res.IsSuccess() {
+		t.Error("expected error for missing post")
+	}
+}
@@ -0,0 +1,371 @@
+// Package resolve turns references to VK objects in any form users paste them,
+// e.g. links, screen names or raw ids, into typed ids.
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	f "github.com/rprtr258/go-flow/fun"
+	r "github.com/rprtr258/go-flow/result"
+	vk "github.com/rprtr258/vk-utils/pkg"
+)
+
+// Kind is kind of object reference points to.
+type Kind uint8
+
+const (
+	KindUser Kind = iota + 1
+	KindGroup
+	KindPost
+	KindComment
+	KindPhoto
+	KindVideo
+	KindPoll
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case KindUser:
+		return "user"
+	case KindGroup:
+		return "group"
+	case KindPost:
+		return "post"
+	case KindComment:
+		return "comment"
+	case KindPhoto:
+		return "photo"
+	case KindVideo:
+		return "video"
+	case KindPoll:
+		return "poll"
+	default:
+		return "unknown"
+	}
+}
+
+// Object is VK object reference points to.
+type Object struct {
+	Kind Kind
+	// OwnerID is id of user or negated id of group, for users and groups it is object itself.
+	OwnerID vk.OwnerID
+	// ID is id of post, comment, photo, video or poll.
+	ID uint
+	// PostID is id of post comment is left under.
+	PostID uint
+	// Answer is id of poll answer, zero for whole poll.
+	Answer uint
+}
+
+// Post returns id of post or of post comment is left under.
+func (obj Object) Post() vk.PostID {
+	if obj.Kind == KindComment {
+		return vk.PostID{OwnerID: obj.OwnerID, ID: obj.PostID}
+	}
+	return vk.PostID{OwnerID: obj.OwnerID, ID: obj.ID}
+}
+
+// vkHosts are hosts of VK links.
+var vkHosts = map[string]bool{
+	"vk.com":     true,
+	"vk.ru":      true,
+	"m.vk.com":   true,
+	"m.vk.ru":    true,
+	"www.vk.com": true,
+	"www.vk.ru":  true,
+}
+
+var (
+	// wall-1_2, wall1_2_r3, photo-1_2, video-1_2
+	itemRe = regexp.MustCompile(`^(wall|photo|video)(-?\d+)_(\d+)(?:_r(\d+))?$`)
+	// poll-1_2, poll-1_2_3 for answer 3
+	pollRe = regexp.MustCompile(`^poll(-?\d+)_(\d+)(?:_(\d+))?$`)
+	// -1_2
+	postRe = regexp.MustCompile(`^(-?\d+)_(\d+)$`)
+	// id1, club1, public1, event1
+	prefixedRe = regexp.MustCompile(`^(id|club|public|event)(\d+)$`)
+	numberRe   = regexp.MustCompile(`^-?\d+$`)
+	// screen names consist of latin letters, digits, underscores and dots
+	screenNameRe = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+)
+
+// reference is parsed reference: either object itself or screen name to resolve.
+type reference struct {
+	object     f.Option[Object]
+	screenName string
+}
+
+// parse parses reference without calling api.
+func parse(ref string) r.Result[reference] {
+	ref = strings.TrimPrefix(strings.TrimSpace(ref), "@")
+	if ref == "" {
+		return r.Err[reference](errors.New("empty reference"))
+	}
+	if !isLink(ref) {
+		return parseToken(ref)
+	}
+
+	if !strings.Contains(ref, "://") {
+		ref = "https://" + ref
+	}
+	link, err := url.Parse(ref)
+	if err != nil {
+		return r.Err[reference](fmt.Errorf("invalid link %q: %w", ref, err))
+	}
+	if !vkHosts[strings.ToLower(link.Hostname())] {
+		return r.Err[reference](fmt.Errorf("%q is not VK link", ref))
+	}
+
+	query := link.Query()
+	// object opened in modal window, e.g. vk.com/club1?w=wall-1_2 or vk.com/album1?z=photo1_2/album1
+	for _, modal := range []string{"w", "z"} {
+		token, _, _ := strings.Cut(query.Get(modal), "/")
+		if itemRe.MatchString(token) {
+			return withReply(parseToken(token), query)
+		}
+	}
+	token, _, _ := strings.Cut(strings.Trim(link.Path, "/"), "/")
+	if token == "" {
+		return r.Err[reference](fmt.Errorf("link %q points to nothing", ref))
+	}
+	return withReply(parseToken(token), query)
+}
+
+// isLink checks whether reference looks like url rather than id or screen name.
+func isLink(ref string) bool {
+	if strings.Contains(ref, "://") {
+		return true
+	}
+	host, _, _ := strings.Cut(ref, "/")
+	return vkHosts[strings.ToLower(host)]
+}
+
+// withReply makes comment reference from post reference if link has reply param, e.g. vk.com/wall-1_2?reply=3.
+func withReply(ref r.Result[reference], query url.Values) r.Result[reference] {
+	reply := query.Get("reply")
+	if ref.IsErr() || reply == "" {
+		return ref
+	}
+	obj := ref.Unwrap().object
+	if obj.IsNone() || obj.Unwrap().Kind != KindPost {
+		return ref
+	}
+	commentID, err := strconv.ParseUint(reply, 10, 0)
+	if err != nil {
+		return r.Err[reference](fmt.Errorf("invalid comment id %q", reply))
+	}
+	post := obj.Unwrap()
+	return r.Success(reference{object: f.Some(Object{
+		Kind:    KindComment,
+		OwnerID: post.OwnerID,
+		ID:      uint(commentID),
+		PostID:  post.ID,
+	})})
+}
+
+// parseToken parses single path segment or raw reference.
+func parseToken(token string) r.Result[reference] {
+	object := func(obj Object) r.Result[reference] {
+		return r.Success(reference{object: f.Some(obj)})
+	}
+	if m := itemRe.FindStringSubmatch(token); m != nil {
+		ownerID, _ := strconv.Atoi(m[2])
+		id, _ := strconv.ParseUint(m[3], 10, 0)
+		switch {
+		case m[1] == "photo":
+			return object(Object{Kind: KindPhoto, OwnerID: vk.OwnerID(ownerID), ID: uint(id)})
+		case m[1] == "video":
+			return object(Object{Kind: KindVideo, OwnerID: vk.OwnerID(ownerID), ID: uint(id)})
+		case m[4] != "":
+			commentID, _ := strconv.ParseUint(m[4], 10, 0)
+			return object(Object{Kind: KindComment, OwnerID: vk.OwnerID(ownerID), ID: uint(commentID), PostID: uint(id)})
+		default:
+			return object(Object{Kind: KindPost, OwnerID: vk.OwnerID(ownerID), ID: uint(id)})
+		}
+	}
+	if m := pollRe.FindStringSubmatch(token); m != nil {
+		ownerID, _ := strconv.Atoi(m[1])
+		id, _ := strconv.ParseUint(m[2], 10, 0)
+		answer, _ := strconv.ParseUint(m[3], 10, 0)
+		return object(Object{Kind: KindPoll, OwnerID: vk.OwnerID(ownerID), ID: uint(id), Answer: uint(answer)})
+	}
+	if m := postRe.FindStringSubmatch(token); m != nil {
+		ownerID, _ := strconv.Atoi(m[1])
+		id, _ := strconv.ParseUint(m[2], 10, 0)
+		return object(Object{Kind: KindPost, OwnerID: vk.OwnerID(ownerID), ID: uint(id)})
+	}
+	if m := prefixedRe.FindStringSubmatch(token); m != nil {
+		id, _ := strconv.Atoi(m[2])
+		if m[1] == "id" {
+			return object(Object{Kind: KindUser, OwnerID: vk.OwnerID(id)})
+		}
+		return object(Object{Kind: KindGroup, OwnerID: vk.OwnerID(-id)})
+	}
+	if numberRe.MatchString(token) {
+		id, err := strconv.Atoi(token)
+		if err != nil {
+			return r.Err[reference](fmt.Errorf("invalid id %q: %w", token, err))
+		}
+		if id < 0 {
+			return object(Object{Kind: KindGroup, OwnerID: vk.OwnerID(id)})
+		}
+		return object(Object{Kind: KindUser, OwnerID: vk.OwnerID(id)})
+	}
+	if screenNameRe.MatchString(token) {
+		return r.Success(reference{screenName: token})
+	}
+	return r.Err[reference](fmt.Errorf("can't parse VK reference %q", token))
+}
+
+// Resolve finds object reference points to, resolving screen name with api if needed.
+func Resolve(ctx context.Context, client vk.VKClient, ref string) r.Result[Object] {
+	return r.FlatMap(parse(ref), func(parsed reference) r.Result[Object] {
+		if parsed.object.IsSome() {
+			return r.Success(parsed.object.Unwrap())
+		}
+		return r.FlatMap(
+			client.ResolveScreenName(ctx, parsed.screenName),
+			func(name vk.ScreenName) r.Result[Object] {
+				switch name.Type {
+				case "user":
+					return r.Success(Object{Kind: KindUser, OwnerID: vk.OwnerID(name.ObjectID)})
+				case "group", "page", "event":
+					return r.Success(Object{Kind: KindGroup, OwnerID: vk.GroupID(name.ObjectID).Owner()})
+				default:
+					return r.Err[Object](fmt.Errorf("%q is %s, not user or group", parsed.screenName, name.Type))
+				}
+			},
+		)
+	})
+}
+
+func expect(ref string, obj r.Result[Object], kinds ...Kind) r.Result[Object] {
+	return r.FlatMap(obj, func(obj Object) r.Result[Object] {
+		for _, kind := range kinds {
+			if obj.Kind == kind {
+				return r.Success(obj)
+			}
+		}
+		return r.Err[Object](fmt.Errorf("%q is %s, expected %s", ref, obj.Kind, kinds[0]))
+	})
+}
+
+// User resolves reference to user.
+func User(ctx context.Context, client vk.VKClient, ref string) r.Result[vk.UserID] {
+	return r.Map(expect(ref, Resolve(ctx, client, ref), KindUser), func(obj Object) vk.UserID {
+		return obj.OwnerID.UserID()
+	})
+}
+
+// Group resolves reference to group. Positive number is taken as group id too.
+func Group(ctx context.Context, client vk.VKClient, ref string) r.Result[vk.GroupID] {
+	if id, err := strconv.ParseUint(strings.TrimSpace(ref), 10, 0); err == nil && id > 0 {
+		return r.Success(vk.GroupID(id))
+	}
+	return r.Map(expect(ref, Resolve(ctx, client, ref), KindGroup), func(obj Object) vk.GroupID {
+		return obj.OwnerID.GroupID()
+	})
+}
+
+// Owner resolves reference to user or group.
+func Owner(ctx context.Context, client vk.VKClient, ref string) r.Result[vk.OwnerID] {
+	return r.Map(expect(ref, Resolve(ctx, client, ref), KindUser, KindGroup), func(obj Object) vk.OwnerID {
+		return obj.OwnerID
+	})
+}
+
+// Post resolves reference to post. Reference to comment resolves to post comment is left under.
+func Post(ctx context.Context, client vk.VKClient, ref string) r.Result[vk.PostID] {
+	return r.Map(expect(ref, Resolve(ctx, client, ref), KindPost, KindComment), Object.Post)
+}
+
+// Item returns resolver of references to photos, videos or comments as owner and id of item,
+// e.g. to get likers of it.
+func Item(kind Kind) func(context.Context, vk.VKClient, string) r.Result[vk.PostID] {
+	return func(ctx context.Context, client vk.VKClient, ref string) r.Result[vk.PostID] {
+		return r.Map(expect(ref, Resolve(ctx, client, ref), kind), func(obj Object) vk.PostID {
+			return vk.PostID{OwnerID: obj.OwnerID, ID: obj.ID}
+		})
+	}
+}
+
+// Poll resolves reference to poll or its answer, e.g. poll-1_2 or poll-1_2_3 for answer 3.
+func Poll(ctx context.Context, client vk.VKClient, ref string) r.Result[vk.PollAnswerID] {
+	return r.Map(expect(ref, Resolve(ctx, client, ref), KindPoll), func(obj Object) vk.PollAnswerID {
+		return vk.PollAnswerID{OwnerID: obj.OwnerID, PollID: obj.ID, AnswerID: obj.Answer}
+	})
+}
+
+// UserPair resolves pair of user references joined with +, e.g. durov+id1.
+func UserPair(ctx context.Context, client vk.VKClient, ref string) r.Result[[2]vk.UserID] {
+	first, second, ok := strings.Cut(ref, "+")
+	if !ok {
+		return r.Err[[2]vk.UserID](fmt.Errorf("expected two users joined with +, got %q", ref))
+	}
+	return r.FlatMap(User(ctx, client, first), func(a vk.UserID) r.Result[[2]vk.UserID] {
+		return r.Map(User(ctx, client, second), func(b vk.UserID) [2]vk.UserID {
+			return [2]vk.UserID{a, b}
+		})
+	})
+}
+
+// All resolves every reference with resolve, failing with errors of all references that failed.
+func All[A any](ctx context.Context, client vk.VKClient, refs []string, resolve func(context.Context, vk.VKClient, string) r.Result[A]) r.Result[[]A] {
+	res := make([]A, 0, len(refs))
+	var errs []error
+	for _, ref := range refs {
+		x := resolve(ctx, client, ref)
+		if x.IsErr() {
+			errs = append(errs, x.UnwrapErr())
+			continue
+		}
+		res = append(res, x.Unwrap())
+	}
+	if errs != nil {
+		return r.Err[[]A](errors.Join(errs...))
+	}
+	return r.Success(res)
+}
+
+// Source resolves reference to source of users of given kind.
+func Source(ctx context.Context, client vk.VKClient, kind vk.SourceKind, ref string) r.Result[vk.Source] {
+	switch kind {
+	case vk.SourceFriends, vk.SourceFollowers, vk.SourceUser:
+		return r.Map(User(ctx, client, ref), func(id vk.UserID) vk.Source {
+			return vk.Source{Kind: kind, User: id}
+		})
+	case vk.SourceGroupMembers, vk.SourceGroupManagers, vk.SourceMemberFriends:
+		return r.Map(Group(ctx, client, ref), func(id vk.GroupID) vk.Source {
+			return vk.Source{Kind: kind, Group: id}
+		})
+	case vk.SourceLikers, vk.SourceCommenters:
+		return r.Map(Post(ctx, client, ref), func(id vk.PostID) vk.Source {
+			return vk.Source{Kind: kind, Post: id}
+		})
+	case vk.SourcePollVoters:
+		return r.Map(Poll(ctx, client, ref), func(id vk.PollAnswerID) vk.Source {
+			return vk.Source{Kind: kind, Poll: id}
+		})
+	case vk.SourceMutualFriends:
+		return r.Map(UserPair(ctx, client, ref), func(users [2]vk.UserID) vk.Source {
+			return vk.Source{Kind: kind, User: users[0], Target: users[1]}
+		})
+	case vk.SourcePhotoLikers, vk.SourceVideoLikers, vk.SourceCommentLikers:
+		itemKind := map[vk.SourceKind]Kind{
+			vk.SourcePhotoLikers:   KindPhoto,
+			vk.SourceVideoLikers:   KindVideo,
+			vk.SourceCommentLikers: KindComment,
+		}[kind]
+		return r.Map(Item(itemKind)(ctx, client, ref), func(id vk.PostID) vk.Source {
+			return vk.Source{Kind: kind, Item: id}
+		})
+	default:
+		return r.Err[vk.Source](fmt.Errorf("unknown source kind %d", kind))
+	}
+}
@@ -0,0 +1,129 @@
+package resolve
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+func newTestServer() *vktest.Server {
+	return vktest.NewServer(vktest.Fixtures{
+		Users:  map[vk.UserID]vk.User{1: {ID: 1, FirstName: "Pavel", SecondName: "Durov", ScreenName: "durov"}},
+		Groups: map[vk.GroupID]vk.Group{5: {ID: 5, Name: "API", ScreenName: "apiclub"}},
+	})
+}
+
+func TestResolve(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	for ref, want := range map[string]Object{
+		"12":                              {Kind: KindUser, OwnerID: 12},
+		"-7":                              {Kind: KindGroup, OwnerID: -7},
+		"id5":                             {Kind: KindUser, OwnerID: 5},
+		"club7":                           {Kind: KindGroup, OwnerID: -7},
+		"public7":                         {Kind: KindGroup, OwnerID: -7},
+		"@durov":                          {Kind: KindUser, OwnerID: 1},
+		"https://vk.com/apiclub":          {Kind: KindGroup, OwnerID: -5},
+		"-1_2":                            {Kind: KindPost, OwnerID: -1, ID: 2},
+		"https://vk.com/wall-1_2":         {Kind: KindPost, OwnerID: -1, ID: 2},
+		"vk.ru/wall1_2":                   {Kind: KindPost, OwnerID: 1, ID: 2},
+		"https://vk.com/club1?w=wall-1_2": {Kind: KindPost, OwnerID: -1, ID: 2},
+		"wall-1_2_r3":                     {Kind: KindComment, OwnerID: -1, ID: 3, PostID: 2},
+		"https://vk.com/wall-1_2?reply=3": {Kind: KindComment, OwnerID: -1, ID: 3, PostID: 2},
+		"https://m.vk.com/photo-1_2":      {Kind: KindPhoto, OwnerID: -1, ID: 2},
+		"vk.com/album1?z=photo1_2/album1": {Kind: KindPhoto, OwnerID: 1, ID: 2},
+		"video-1_2":                       {Kind: KindVideo, OwnerID: -1, ID: 2},
+		"poll-1_2":                        {Kind: KindPoll, OwnerID: -1, ID: 2},
+		"poll-1_2_3":                      {Kind: KindPoll, OwnerID: -1, ID: 2, Answer: 3},
+	} {
+		obj := Resolve(context.Background(), client, ref)
+		if obj.IsErr() {
+			t.Errorf("%s: %v", ref, obj.UnwrapErr())
+			continue
+		}
+		if got := obj.Unwrap(); got != want {
+			t.Errorf("%s: expected %+v, got %+v", ref, want, got)
+		}
+	}
+	if calls := srv.Calls("utils.resolveScreenName"); calls != 2 {
+		t.Errorf("expected only screen names to be resolved with api, got %d calls", calls)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	for ref, want := range map[string]string{
+		"":                               "empty reference",
+		"https://example.com/wall1_2":    "is not VK link",
+		"https://vk.com/":                "points to nothing",
+		"what?":                          "can't parse VK reference",
+		"https://vk.com/wall1_2?reply=x": "invalid comment id",
+	} {
+		obj := Resolve(context.Background(), client, ref)
+		if obj.IsSuccess() {
+			t.Errorf("%s: expected error, got %+v", ref, obj.Unwrap())
+			continue
+		}
+		if err := obj.UnwrapErr().Error(); !strings.Contains(err, want) {
+			t.Errorf("%s: expected error containing %q, got %q", ref, want, err)
+		}
+	}
+	if obj := Resolve(context.Background(), client, "nobody"); obj.IsSuccess() {
+		t.Errorf("expected unknown screen name to fail, got %+v", obj.Unwrap())
+	}
+}
+
+func TestResolveExpectedKind(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	if id := Group(ctx, client, "5"); id.IsErr() || id.Unwrap() != 5 {
+		t.Errorf("expected positive number to be group id, got %v", id)
+	}
+	if id := Post(ctx, client, "wall-1_2_r3"); id.IsErr() || id.Unwrap() != (vk.PostID{OwnerID: -1, ID: 2}) {
+		t.Errorf("expected comment to resolve to its post, got %v", id)
+	}
+	if id := User(ctx, client, "club1"); id.IsSuccess() || !strings.Contains(id.UnwrapErr().Error(), "is group, expected user") {
+		t.Errorf("expected group to be rejected as user, got %v", id)
+	}
+	if users := UserPair(ctx, client, "durov+id2"); users.IsErr() || users.Unwrap() != [2]vk.UserID{1, 2} {
+		t.Errorf("expected users 1 and 2, got %v", users)
+	}
+	if users := UserPair(ctx, client, "durov"); users.IsSuccess() {
+		t.Errorf("expected single user to be rejected as pair, got %v", users.Unwrap())
+	}
+	if ids := All(ctx, client, []string{"1", "club1", "2"}, User); ids.IsSuccess() {
+		t.Errorf("expected group to fail list of users, got %v", ids.Unwrap())
+	}
+
+	for _, test := range []struct {
+		kind vk.SourceKind
+		ref  string
+		want vk.Source
+	}{
+		{vk.SourceFriends, "durov", vk.Source{Kind: vk.SourceFriends, User: 1}},
+		{vk.SourceGroupManagers, "apiclub", vk.Source{Kind: vk.SourceGroupManagers, Group: 5}},
+		{vk.SourceLikers, "wall-1_2", vk.Source{Kind: vk.SourceLikers, Post: vk.PostID{OwnerID: -1, ID: 2}}},
+		{vk.SourcePollVoters, "poll-1_2_3", vk.Source{Kind: vk.SourcePollVoters, Poll: vk.PollAnswerID{OwnerID: -1, PollID: 2, AnswerID: 3}}},
+		{vk.SourceMutualFriends, "1+2", vk.Source{Kind: vk.SourceMutualFriends, User: 1, Target: 2}},
+		{vk.SourceCommentLikers, "wall-1_2_r3", vk.Source{Kind: vk.SourceCommentLikers, Item: vk.PostID{OwnerID: -1, ID: 3}}},
+	} {
+		source := Source(ctx, client, test.kind, test.ref)
+		if source.IsErr() || source.Unwrap() != test.want {
+			t.Errorf("%s: expected %v, got %v", test.ref, test.want, source)
+		}
+	}
+	if source := Source(ctx, client, vk.SourcePhotoLikers, "video-1_2"); source.IsSuccess() {
+		t.Errorf("expected video to be rejected as photo, got %v", source.Unwrap())
+	}
+}
@@ -0,0 +1,108 @@
+package vkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy is how requests failed due to network errors, api server errors
+// or internal VK errors are retried. Waits between attempts grow exponentially with random jitter.
+type RetryPolicy struct {
+	// MaxAttempts is max number of attempts to send request, including the first one.
+	MaxAttempts int
+	// InitialWait is wait before the first retry.
+	InitialWait time.Duration
+	// MaxWait caps single wait between attempts.
+	MaxWait time.Duration
+	// MaxElapsed is max time spent on request including all retries, zero means no limit.
+	MaxElapsed time.Duration
+	// Multiplier is how much wait grows after every attempt.
+	Multiplier float64
+}
+
+// DefaultRetryPolicy is retry policy used by VKClient by default.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 8,
+	InitialWait: 500 * time.Millisecond,
+	MaxWait:     30 * time.Second,
+	MaxElapsed:  5 * time.Minute,
+	Multiplier:  2,
+}
+
+// HTTPStatusError is returned when api server responds with non 200 status.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (err HTTPStatusError) Error() string {
+	return fmt.Sprintf("api server responded with %d %s", err.StatusCode, http.StatusText(err.StatusCode))
+}
+
+// backoff returns random wait before retry after given number of failed attempts.
+func (policy RetryPolicy) backoff(failures int) time.Duration {
+	maxWait := float64(policy.InitialWait) * math.Pow(policy.Multiplier, float64(failures-1))
+	if maxWait > float64(policy.MaxWait) {
+		maxWait = float64(policy.MaxWait)
+	}
+	if maxWait < 1 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(maxWait)))
+}
+
+// expired checks whether request started at start would run out of time after waiting for given duration.
+func (policy RetryPolicy) expired(start time.Time, wait time.Duration) bool {
+	return policy.MaxElapsed > 0 && time.Since(start)+wait > policy.MaxElapsed
+}
+
+// wait sleeps before next attempt. It returns false if request should not be retried anymore.
+func (policy RetryPolicy) wait(ctx context.Context, failures int, start time.Time) bool {
+	if failures >= policy.MaxAttempts {
+		return false
+	}
+	wait := policy.backoff(failures)
+	if policy.expired(start, wait) {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// isRetryable checks whether request failed due to transient error and can be retried.
+func isRetryable(err error) bool {
+	var (
+		code      ErrorCode
+		statusErr HTTPStatusError
+		netErr    net.Error
+	)
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &code):
+		return code == ErrUnknown || code == ErrInternal
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	default:
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.ECONNREFUSED) ||
+			errors.Is(err, syscall.EPIPE) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, io.EOF)
+	}
+}
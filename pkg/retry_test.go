@@ -0,0 +1,69 @@
+package vkutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialWait: 100 * time.Millisecond, MaxWait: time.Second, Multiplier: 2}
+	for failures, maxWait := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		for i := 0; i < 100; i++ {
+			if wait := policy.backoff(failures); wait < 0 || wait >= maxWait {
+				t.Fatalf("expected wait after %d failures below %v, got %v", failures, maxWait, wait)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyWait(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialWait: time.Millisecond, MaxWait: time.Millisecond, MaxElapsed: time.Minute, Multiplier: 2}
+	if !policy.wait(context.Background(), 1, time.Now()) {
+		t.Error("expected retry after first failure")
+	}
+	if policy.wait(context.Background(), 3, time.Now()) {
+		t.Error("expected no retry after max attempts")
+	}
+	if policy.wait(context.Background(), 1, time.Now().Add(-time.Hour)) {
+		t.Error("expected no retry after max elapsed time")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	policy.InitialWait, policy.MaxWait = time.Hour, time.Hour
+	policy.MaxElapsed = 0
+	if policy.wait(ctx, 1, time.Now()) {
+		t.Error("expected no retry with canceled context")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{ErrUnknown, true},
+		{ApiCallError{vkError: VkError{Code: ErrInternal}}, true},
+		{ApiCallError{vkError: VkError{Code: ErrAccessDenied}}, false},
+		{HTTPStatusError{StatusCode: http.StatusBadGateway}, true},
+		{HTTPStatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{HTTPStatusError{StatusCode: http.StatusNotFound}, false},
+		{fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), true},
+		{context.Canceled, false},
+		{fmt.Errorf("request: %w", context.DeadlineExceeded), false},
+	} {
+		if got := isRetryable(test.err); got != test.want {
+			t.Errorf("%v: expected retryable %v, got %v", test.err, test.want, got)
+		}
+	}
+}
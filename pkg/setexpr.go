@@ -0,0 +1,366 @@
+package vkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	f "github.com/rprtr258/go-flow/fun"
+	r "github.com/rprtr258/go-flow/result"
+)
+
+// SetExpr is expression over sources of users, e.g. (group:1 & likers:1_2) - friends:3.
+type SetExpr interface {
+	// sources lists sources expression uses, possibly with repeats.
+	sources() []Source
+	// eval finds owners in expression set given owners of every source.
+	eval(sets map[Source]f.Set[OwnerID]) f.Set[OwnerID]
+}
+
+type sourceExpr struct {
+	source Source
+}
+
+func (e sourceExpr) sources() []Source {
+	return []Source{e.source}
+}
+
+func (e sourceExpr) eval(sets map[Source]f.Set[OwnerID]) f.Set[OwnerID] {
+	return sets[e.source]
+}
+
+// binaryExpr is union (|), intersection (&), difference (-) or symmetric difference (^) of two sets.
+type binaryExpr struct {
+	op          string
+	left, right SetExpr
+}
+
+func (e binaryExpr) sources() []Source {
+	return append(e.left.sources(), e.right.sources()...)
+}
+
+func (e binaryExpr) eval(sets map[Source]f.Set[OwnerID]) f.Set[OwnerID] {
+	left, right := e.left.eval(sets), e.right.eval(sets)
+	res := f.NewSet[OwnerID]()
+	switch e.op {
+	case "|":
+		for id := range left {
+			res.Add(id)
+		}
+		for id := range right {
+			res.Add(id)
+		}
+	case "&":
+		res = f.Intersect(left, right)
+	case "-":
+		for id := range left {
+			if !right.Contains(id) {
+				res.Add(id)
+			}
+		}
+	case "^":
+		for id := range left {
+			if !right.Contains(id) {
+				res.Add(id)
+			}
+		}
+		for id := range right {
+			if !left.Contains(id) {
+				res.Add(id)
+			}
+		}
+	}
+	return res
+}
+
+// atLeastExpr is set of owners that are in at least n of sets.
+type atLeastExpr struct {
+	n    uint
+	args []SetExpr
+}
+
+func (e atLeastExpr) sources() []Source {
+	res := []Source{}
+	for _, arg := range e.args {
+		res = append(res, arg.sources()...)
+	}
+	return res
+}
+
+func (e atLeastExpr) eval(sets map[Source]f.Set[OwnerID]) f.Set[OwnerID] {
+	counter := f.NewCounter[OwnerID]()
+	for _, arg := range e.args {
+		for id := range arg.eval(sets) {
+			counter[id]++
+		}
+	}
+	res := f.NewSet[OwnerID]()
+	for id, count := range counter {
+		if count >= e.n {
+			res.Add(id)
+		}
+	}
+	return res
+}
+
+// sourceKinds maps names of sources in expressions and weights to their kinds.
+var sourceKinds = map[string]SourceKind{
+	"friends":        SourceFriends,
+	"group":          SourceGroupMembers,
+	"groups":         SourceGroupMembers,
+	"members":        SourceGroupMembers,
+	"subscribers":    SourceGroupMembers, // subscribers of public page are its members in api
+	"followers":      SourceFollowers,
+	"user":           SourceUser,
+	"users":          SourceUser,
+	"likers":         SourceLikers,
+	"commenters":     SourceCommenters,
+	"managers":       SourceGroupManagers,
+	"member-friends": SourceMemberFriends,
+	"voters":         SourcePollVoters,
+	"mutual":         SourceMutualFriends,
+	"photo-likers":   SourcePhotoLikers,
+	"video-likers":   SourceVideoLikers,
+	"comment-likers": SourceCommentLikers,
+}
+
+// ParseSourceKind finds kind of source by its name in expressions, e.g. friends or likers.
+func ParseSourceKind(name string) (SourceKind, error) {
+	kind, ok := sourceKinds[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown source kind %q", name)
+	}
+	return kind, nil
+}
+
+// atLeastKeyword starts "in at least N of" operator: atleast(N, expr, expr, ...).
+const atLeastKeyword = "atleast"
+
+// tokenizeSetExpr splits expression into punctuation and words. Words are separated by
+// whitespace and punctuation except "-", so that it can be used in references, e.g. group:-1.
+// Difference operator is thus a standalone "-" word or "-" followed by source or "(".
+func tokenizeSetExpr(expr string) []string {
+	tokens := []string{}
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for i, c := range expr {
+		switch {
+		case strings.ContainsRune("()|&^,", c), c == '-' && startsOperand(expr[i+1:]):
+			flush()
+			tokens = append(tokens, string(c))
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+		default:
+			word.WriteRune(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// startsOperand checks whether expression starts with source, "(" or atleast(...).
+func startsOperand(expr string) bool {
+	word, _, _ := strings.Cut(expr, " ")
+	return strings.HasPrefix(expr, "(") || strings.HasPrefix(expr, atLeastKeyword+"(") || sourceStart(word)
+}
+
+// setExprParser is recursive descent parser of set expressions. Operators |, - and ^
+// have lower precedence than &, all of them are left associative.
+type setExprParser struct {
+	tokens  []string
+	pos     int
+	resolve func(kind SourceKind, ref string) r.Result[Source]
+}
+
+func (p *setExprParser) peek(offset int) string {
+	if p.pos+offset >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *setExprParser) next() string {
+	token := p.peek(0)
+	p.pos++
+	return token
+}
+
+func (p *setExprParser) expect(token string) error {
+	if got := p.peek(0); got != token {
+		return p.unexpected(token)
+	}
+	p.next()
+	return nil
+}
+
+// unexpected reports that current token is not what is expected.
+func (p *setExprParser) unexpected(expected string) error {
+	got := p.peek(0)
+	if got == "" {
+		return fmt.Errorf("unexpected end of expression, expected %s", expected)
+	}
+	return fmt.Errorf("unexpected %q (token %d of %d), expected %s", got, p.pos+1, len(p.tokens), expected)
+}
+
+// sourceStart checks whether token starts source, e.g. "friends:1".
+func sourceStart(token string) bool {
+	kind, _, ok := strings.Cut(token, ":")
+	_, known := sourceKinds[kind]
+	return ok && known
+}
+
+func (p *setExprParser) expr() (SetExpr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(0); op == "|" || op == "-" || op == "^"; op = p.peek(0) {
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *setExprParser) term() (SetExpr, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek(0) == "&" {
+		p.next()
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *setExprParser) factor() (SetExpr, error) {
+	switch token := p.peek(0); {
+	case token == "(":
+		p.next()
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case token == atLeastKeyword && p.peek(1) == "(":
+		return p.atLeast()
+	case sourceStart(token):
+		return p.source()
+	default:
+		return nil, p.unexpected("source, \"(\" or " + atLeastKeyword)
+	}
+}
+
+// atLeast parses atleast(N, expr, expr, ...).
+func (p *setExprParser) atLeast() (SetExpr, error) {
+	p.next()
+	p.next()
+	n, err := strconv.ParseUint(p.peek(0), 10, 0)
+	if err != nil {
+		return nil, p.unexpected("number of sets")
+	}
+	p.next()
+	args := []SetExpr{}
+	for p.peek(0) == "," {
+		p.next()
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if n == 0 || n > uint64(len(args)) {
+		return nil, fmt.Errorf("%s(%d, ...) of %d sets is always empty or all of them", atLeastKeyword, n, len(args))
+	}
+	return atLeastExpr{n: uint(n), args: args}, nil
+}
+
+// source parses kind:ref[,ref...], several refs make union of sources.
+func (p *setExprParser) source() (SetExpr, error) {
+	name, ref, _ := strings.Cut(p.next(), ":")
+	kind := sourceKinds[name]
+	refs := []string{ref}
+	// comma followed by plain word continues list of refs, otherwise it separates atleast arguments
+	for p.peek(0) == "," && p.peek(1) != "" && p.peek(1) != "-" && !strings.ContainsAny(p.peek(1), "()|&^,") &&
+		!sourceStart(p.peek(1)) && !(p.peek(1) == atLeastKeyword && p.peek(2) == "(") {
+		p.next()
+		refs = append(refs, p.next())
+	}
+
+	var res SetExpr
+	errs := []error{}
+	for _, ref := range refs {
+		if ref == "" {
+			errs = append(errs, fmt.Errorf("empty reference in %s source", name))
+			continue
+		}
+		source := p.resolve(kind, ref)
+		if source.IsErr() {
+			errs = append(errs, source.UnwrapErr())
+			continue
+		}
+		e := sourceExpr{source: source.Unwrap()}
+		if res == nil {
+			res = e
+		} else {
+			res = binaryExpr{op: "|", left: res, right: e}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return res, nil
+}
+
+// ParseSetExpr parses set expression. Sources are written as kind:ref, where kind is one of
+// friends, group, followers, users, likers, commenters, managers, member-friends, voters, mutual,
+// photo-likers, video-likers or comment-likers, several comma separated refs make union.
+// Sets are combined with | (union), & (intersection), - (difference, surrounded by spaces or followed by source or "("),
+// ^ (symmetric difference) and atleast(N, expr, expr, ...) (owners in at least N of sets).
+// References are turned into sources by resolve.
+func ParseSetExpr(expr string, resolve func(kind SourceKind, ref string) r.Result[Source]) r.Result[SetExpr] {
+	p := &setExprParser{
+		tokens:  tokenizeSetExpr(expr),
+		resolve: resolve,
+	}
+	e, err := p.expr()
+	if err != nil {
+		return r.Err[SetExpr](fmt.Errorf("invalid expression: %w", err))
+	}
+	if p.pos < len(p.tokens) {
+		return r.Err[SetExpr](fmt.Errorf("invalid expression: %w", p.unexpected("operator")))
+	}
+	return r.Success(e)
+}
+
+// EvalSetExpr finds owners in set expression. Every owner is counted in how many of expression
+// sources they are. Sources that failed are evaluated with users fetched before failure and are
+// listed as incomplete.
+func EvalSetExpr(ctx context.Context, client VKClient, expr SetExpr, filter CountFilter) MembershipCountResult {
+	sources := uniqueSources(expr.sources())
+	sets := fetchSources(ctx, client, sources, filter)
+	counts, incomplete := countMembership(ctx, client, expr.eval(sets.ids), sources, sets, filter)
+	return MembershipCountResult{
+		Sources:    sources,
+		Counts:     counts,
+		Incomplete: incomplete,
+	}
+}
@@ -0,0 +1,100 @@
+package vkutils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	f "github.com/rprtr258/go-flow/fun"
+	r "github.com/rprtr258/go-flow/result"
+)
+
+// resolveFriends resolves reference to friends of user with id written in reference.
+func resolveFriends(kind SourceKind, ref string) r.Result[Source] {
+	id, err := strconv.Atoi(ref)
+	if err != nil {
+		return r.Err[Source](fmt.Errorf("invalid id %q", ref))
+	}
+	return r.Success(Source{Kind: kind, User: UserID(id)})
+}
+
+func TestTokenizeSetExpr(t *testing.T) {
+	for expr, want := range map[string][]string{
+		"group:-1 - friends:2":         {"group:-1", "-", "friends:2"},
+		"group:-1-friends:2":           {"group:-1", "-", "friends:2"},
+		"group:-1 -(friends:2)":        {"group:-1", "-", "(", "friends:2", ")"},
+		"likers:wall-1_2&users:1,2":    {"likers:wall-1_2", "&", "users:1", ",", "2"},
+		"atleast(2,group:a|group:b-c)": {"atleast", "(", "2", ",", "group:a", "|", "group:b-c", ")"},
+	} {
+		if got := tokenizeSetExpr(expr); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: expected tokens %q, got %q", expr, want, got)
+		}
+	}
+}
+
+func TestSetExpr(t *testing.T) {
+	sets := map[Source]f.Set[OwnerID]{}
+	for user, ids := range map[UserID][]OwnerID{
+		1: {1, 2, 3},
+		2: {2, 3, 4},
+		3: {3, 5},
+	} {
+		set := f.NewSet[OwnerID]()
+		for _, id := range ids {
+			set.Add(id)
+		}
+		sets[Source{Kind: SourceFriends, User: user}] = set
+	}
+
+	for expr, want := range map[string][]OwnerID{
+		"friends:1 | friends:2":                       {1, 2, 3, 4},
+		"friends:1 & friends:2":                       {2, 3},
+		"friends:1 - friends:2":                       {1},
+		"friends:1-friends:2":                         {1},
+		"friends:1 ^ friends:2":                       {1, 4},
+		"friends:1 | friends:2 & friends:3":           {1, 2, 3},
+		"(friends:1 | friends:2) & friends:3":         {3},
+		"friends:1 - friends:2 - friends:3":           {1},
+		"friends:2,3 & friends:1":                     {2, 3},
+		"atleast(2, friends:1, friends:2, friends:3)": {2, 3},
+		"atleast(2, friends:1, friends:2,3)":          {2, 3},
+	} {
+		e := ParseSetExpr(expr, resolveFriends)
+		if e.IsErr() {
+			t.Errorf("%s: %v", expr, e.UnwrapErr())
+			continue
+		}
+		got := []OwnerID{}
+		for id := range e.Unwrap().eval(sets) {
+			got = append(got, id)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: expected %v, got %v", expr, want, got)
+		}
+	}
+}
+
+func TestParseSetExprErrors(t *testing.T) {
+	for expr, want := range map[string]string{
+		"friends:1 friends:2":              `unexpected "friends:2" (token 2 of 2), expected operator`,
+		"friends:1 | )":                    `unexpected ")" (token 3 of 3), expected source`,
+		"(friends:1":                       "unexpected end of expression, expected )",
+		"atleast(x, friends:1)":            `unexpected "x" (token 3 of 6), expected number of sets`,
+		"atleast(3, friends:1, friends:2)": "atleast(3, ...) of 2 sets",
+		"foo:1":                            `unexpected "foo:1" (token 1 of 1)`,
+		"friends:":                         "empty reference in friends source",
+		"friends:x":                        `invalid id "x"`,
+	} {
+		e := ParseSetExpr(expr, resolveFriends)
+		if e.IsSuccess() {
+			t.Errorf("%s: expected error", expr)
+			continue
+		}
+		if err := e.UnwrapErr().Error(); !strings.Contains(err, want) {
+			t.Errorf("%s: expected error containing %q, got %q", expr, want, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package vkutils
+
+import (
+	"errors"
+	"sync"
+
+	f "github.com/rprtr258/go-flow/fun"
+	s "github.com/rprtr258/go-flow/stream"
+)
+
+// ErrStream is stream that can fail. When stream has ended, Err tells whether
+// all elements were received or stream was cut short by error.
+type ErrStream[A any] interface {
+	s.Stream[A]
+	// Err returns error stream ended with, nil if stream is complete or not ended yet.
+	Err() error
+}
+
+// errorList collects errors from concurrently running streams.
+type errorList struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (l *errorList) add(err error) {
+	if err == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err)
+}
+
+// Err returns all collected errors joined.
+func (l *errorList) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return errors.Join(l.errs...)
+}
+
+type withErrImpl[A any] struct {
+	s.Stream[A]
+	err func() error
+}
+
+func (xs withErrImpl[A]) Err() error {
+	return xs.err()
+}
+
+// withErr makes ErrStream from stream which errors are reported by err.
+func withErr[A any](xs s.Stream[A], err func() error) ErrStream[A] {
+	return withErrImpl[A]{xs, err}
+}
+
+// mapErr converts values of stream keeping its error.
+func mapErr[A, B any](xs ErrStream[A], fn func(A) B) ErrStream[B] {
+	return withErr(s.Map[A, B](xs, fn), xs.Err)
+}
+
+// gatherErr merges streams concurrently, resulting stream fails with all errors of merged streams.
+func gatherErr[A any](xss []ErrStream[A]) ErrStream[A] {
+	streams := make([]s.Stream[A], 0, len(xss))
+	for _, xs := range xss {
+		streams = append(streams, xs)
+	}
+	return withErr(s.Gather(streams), func() error {
+		errs := make([]error, 0, len(xss))
+		for _, xs := range xss {
+			errs = append(errs, xs.Err())
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// pagedStream is stream of elements of pages. It stops on the first page that failed.
+type pagedStream[A any] struct {
+	pager Pager[A]
+	page  []A
+	done  bool
+	err   error
+}
+
+func (xs *pagedStream[A]) Next() f.Option[A] {
+	for len(xs.page) == 0 {
+		if xs.done {
+			return f.None[A]()
+		}
+		pageResult := xs.pager.NextPage()
+		if pageResult.IsErr() {
+			xs.done, xs.err = true, pageResult.UnwrapErr()
+			return f.None[A]()
+		}
+		page := pageResult.Unwrap()
+		if page.IsNone() {
+			xs.done = true
+			return f.None[A]()
+		}
+		xs.page = page.Unwrap()
+	}
+	x := xs.page[0]
+	xs.page = xs.page[1:]
+	return f.Some(x)
+}
+
+func (xs *pagedStream[A]) Err() error {
+	return xs.err
+}
@@ -0,0 +1,162 @@
+package vkutils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// how long token is not used after getting error
+const (
+	tooManyRequestsCooldown = time.Second
+	floodControlCooldown    = 30 * time.Second
+	rateLimitCooldown       = time.Hour
+)
+
+// ErrNoValidTokens is returned when all access tokens failed authorization.
+var ErrNoValidTokens = fmt.Errorf("all access tokens are invalid: %w", ErrAuthFailed)
+
+type poolToken struct {
+	token         string
+	limiter       *rateLimiter
+	disabledUntil time.Time
+	// disabledBy is error code token got disabled by
+	disabledBy ErrorCode
+	invalid    bool
+}
+
+// tokenPool spreads requests between access tokens, each with its own rate limiter.
+// Tokens hitting too many requests error, flood control or rate limit are not used for a while, invalid tokens
+// are not used at all.
+type tokenPool struct {
+	mu     sync.Mutex
+	tokens []*poolToken
+	next   int
+	// maxWait is max time to wait for disabled token, zero means no limit
+	maxWait time.Duration
+	logger  *slog.Logger
+}
+
+func newTokenPool(tokens []string, rps float64, maxWait time.Duration, logger *slog.Logger) *tokenPool {
+	pool := &tokenPool{
+		tokens:  make([]*poolToken, 0, len(tokens)),
+		maxWait: maxWait,
+		logger:  logger,
+	}
+	for _, token := range tokens {
+		pool.tokens = append(pool.tokens, &poolToken{
+			token:   token,
+			limiter: newRateLimiter(rps),
+		})
+	}
+	return pool
+}
+
+// pick chooses usable token that can be used soonest, checking tokens in round robin order.
+// If no token is usable now, returns how long to wait for one and error code it was disabled by.
+func (pool *tokenPool) pick(now time.Time) (*poolToken, time.Duration, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var (
+		best      *poolToken
+		bestIndex int
+		bestDelay time.Duration
+		enableIn  time.Duration = -1
+		enableBy  ErrorCode
+	)
+	for i := range pool.tokens {
+		index := (pool.next + i) % len(pool.tokens)
+		tok := pool.tokens[index]
+		switch {
+		case tok.invalid:
+			continue
+		case now.Before(tok.disabledUntil):
+			if wait := tok.disabledUntil.Sub(now); enableIn < 0 || wait < enableIn {
+				enableIn, enableBy = wait, tok.disabledBy
+			}
+			continue
+		}
+		if delay := tok.limiter.delay(); best == nil || delay < bestDelay {
+			best, bestIndex, bestDelay = tok, index, delay
+		}
+	}
+	switch {
+	case best != nil:
+		pool.next = (bestIndex + 1) % len(pool.tokens)
+		return best, 0, nil
+	case enableIn >= 0:
+		return nil, enableIn, enableBy
+	default:
+		return nil, 0, ErrNoValidTokens
+	}
+}
+
+// acquire waits for token to become usable and its rate limiter to allow request.
+// If all tokens are disabled for longer than maxWait, returns error they were disabled by.
+func (pool *tokenPool) acquire(ctx context.Context) (*poolToken, error) {
+	for {
+		tok, wait, err := pool.pick(time.Now())
+		switch {
+		case tok != nil:
+			return tok, tok.limiter.Wait(ctx)
+		case wait == 0:
+			return nil, err
+		case pool.maxWait > 0 && wait > pool.maxWait:
+			return nil, fmt.Errorf("all access tokens are disabled for %v: %w", wait.Round(time.Second), err)
+		}
+		pool.logger.Warn("all access tokens are disabled, waiting", "wait", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// report adjusts token state by error code of response received with it, zero code means success.
+func (pool *tokenPool) report(tok *poolToken, code ErrorCode) {
+	switch code {
+	case 0:
+		tok.limiter.restore()
+		return
+	case ErrTooManyRequests:
+		tok.limiter.backoff()
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	switch code {
+	case ErrTooManyRequests:
+		tok.disabledUntil = time.Now().Add(tooManyRequestsCooldown)
+	case ErrFloodControl:
+		tok.disabledUntil = time.Now().Add(floodControlCooldown)
+	case ErrRateLimit:
+		tok.disabledUntil = time.Now().Add(rateLimitCooldown)
+	case ErrAuthFailed:
+		tok.invalid = true
+		pool.logger.Warn("access token is invalid", "error_code", code)
+		return
+	default:
+		return
+	}
+	tok.disabledBy = code
+	pool.logger.Warn("access token disabled", "error_code", code, "until", tok.disabledUntil)
+}
+
+// hasUsable checks whether some token can be used right now.
+func (pool *tokenPool) hasUsable() bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	now := time.Now()
+	for _, tok := range pool.tokens {
+		if !tok.invalid && !now.Before(tok.disabledUntil) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package vkutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func newTestPool(tokens ...string) *tokenPool {
+	return newTokenPool(tokens, 0, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// picks returns tokens picked n times in a row.
+func picks(t *testing.T, pool *tokenPool, n int) []string {
+	t.Helper()
+	res := make([]string, n)
+	for i := range res {
+		tok, wait, err := pool.pick(time.Now())
+		if err != nil || tok == nil {
+			t.Fatalf("expected usable token, got wait %v, error %v", wait, err)
+		}
+		res[i] = tok.token
+	}
+	return res
+}
+
+func equalTokens(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTokenPoolRoundRobin(t *testing.T) {
+	pool := newTestPool("a", "b", "c")
+	if got, want := picks(t, pool, 4), []string{"a", "b", "c", "a"}; !equalTokens(got, want) {
+		t.Errorf("expected tokens %v, got %v", want, got)
+	}
+}
+
+func TestTokenPoolSkipsDisabled(t *testing.T) {
+	pool := newTestPool("a", "b", "c")
+	pool.report(pool.tokens[1], ErrFloodControl)
+	// rotation continues after picked token, so tokens around disabled one are used evenly
+	if got, want := picks(t, pool, 4), []string{"a", "c", "a", "c"}; !equalTokens(got, want) {
+		t.Errorf("expected tokens %v, got %v", want, got)
+	}
+	if until := time.Until(pool.tokens[1].disabledUntil); until <= 0 || until > floodControlCooldown {
+		t.Errorf("expected token to be disabled for %v, got %v", floodControlCooldown, until)
+	}
+}
+
+func TestTokenPoolTooManyRequests(t *testing.T) {
+	pool := newTestPool("a")
+	pool.report(pool.tokens[0], ErrTooManyRequests)
+	if pool.hasUsable() {
+		t.Fatal("expected token to cool down")
+	}
+	tok, wait, err := pool.pick(time.Now())
+	if tok != nil || !errors.Is(err, ErrTooManyRequests) || wait <= 0 || wait > tooManyRequestsCooldown {
+		t.Fatalf("expected to wait up to %v, got token %v, wait %v, error %v", tooManyRequestsCooldown, tok, wait, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*tooManyRequestsCooldown)
+	defer cancel()
+	start := time.Now()
+	if _, err := pool.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < tooManyRequestsCooldown/2 {
+		t.Errorf("expected acquire to wait for cooldown, took %v", elapsed)
+	}
+}
+
+func TestTokenPoolInvalid(t *testing.T) {
+	pool := newTestPool("a", "b")
+	pool.report(pool.tokens[0], ErrAuthFailed)
+	if got, want := picks(t, pool, 2), []string{"b", "b"}; !equalTokens(got, want) {
+		t.Errorf("expected tokens %v, got %v", want, got)
+	}
+	pool.report(pool.tokens[1], ErrAuthFailed)
+	if _, err := pool.acquire(context.Background()); !errors.Is(err, ErrNoValidTokens) || !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected no valid tokens error, got %v", err)
+	}
+}
+
+func TestTokenPoolWaitLimit(t *testing.T) {
+	pool := newTestPool("a", "b")
+	pool.maxWait = time.Minute
+	pool.report(pool.tokens[0], ErrRateLimit)
+	pool.report(pool.tokens[1], ErrRateLimit)
+	start := time.Now()
+	if _, err := pool.acquire(context.Background()); !errors.Is(err, ErrRateLimit) {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected acquire not to wait for rate limit, took %v", elapsed)
+	}
+}
@@ -0,0 +1,135 @@
+package vkutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SupportedUserFields are profile fields that can be requested with WithUserFields
+// and are decoded into User.
+var SupportedUserFields = []string{
+	"screen_name",
+	"deactivated",
+	"sex",
+	"bdate",
+	"city",
+	"country",
+	"last_seen",
+	"followers_count",
+	"can_access_closed",
+	"verified",
+}
+
+// Sex of user as VK reports it.
+type Sex uint8
+
+const (
+	SexUnknown Sex = iota
+	SexFemale
+	SexMale
+)
+
+func (sex Sex) String() string {
+	switch sex {
+	case SexFemale:
+		return "female"
+	case SexMale:
+		return "male"
+	default:
+		return "unknown"
+	}
+}
+
+// Location is city or country of user.
+type Location struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+}
+
+// title returns title of location, empty if location is not set.
+func (location *Location) title() string {
+	if location == nil {
+		return ""
+	}
+	return location.Title
+}
+
+// LastSeen is when and from which platform user was online last time.
+type LastSeen struct {
+	Time     uint `json:"time"`
+	Platform uint `json:"platform"`
+}
+
+// User is user profile. Fields other than id and names are set only if requested with WithUserFields.
+type User struct {
+	ID              UserID    `json:"id"`
+	FirstName       string    `json:"first_name"`
+	SecondName      string    `json:"last_name"`
+	ScreenName      string    `json:"screen_name,omitempty"`
+	Deactivated     string    `json:"deactivated,omitempty"`
+	Sex             Sex       `json:"sex,omitempty"`
+	BDate           string    `json:"bdate,omitempty"`
+	City            *Location `json:"city,omitempty"`
+	Country         *Location `json:"country,omitempty"`
+	LastSeen        *LastSeen `json:"last_seen,omitempty"`
+	FollowersCount  uint      `json:"followers_count,omitempty"`
+	CanAccessClosed Bool      `json:"can_access_closed,omitempty"`
+	Verified        Bool      `json:"verified,omitempty"`
+}
+
+// Field returns value of profile field by its api name, e.g. "screen_name", formatted as string.
+func (user User) Field(name string) string {
+	switch name {
+	case "id":
+		return fmt.Sprint(user.ID)
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.SecondName
+	case "screen_name":
+		return user.ScreenName
+	case "deactivated":
+		return user.Deactivated
+	case "sex":
+		return user.Sex.String()
+	case "bdate":
+		return user.BDate
+	case "city":
+		return user.City.title()
+	case "country":
+		return user.Country.title()
+	case "last_seen":
+		if user.LastSeen == nil {
+			return ""
+		}
+		return fmt.Sprint(user.LastSeen.Time)
+	case "followers_count":
+		return fmt.Sprint(user.FollowersCount)
+	case "can_access_closed":
+		return fmt.Sprint(user.CanAccessClosed)
+	case "verified":
+		return fmt.Sprint(user.Verified)
+	default:
+		return ""
+	}
+}
+
+// ValidateUserFields checks that all fields are in SupportedUserFields.
+func ValidateUserFields(fields []string) error {
+	for _, field := range fields {
+		supported := false
+		for _, supportedField := range SupportedUserFields {
+			supported = supported || field == supportedField
+		}
+		if !supported {
+			return fmt.Errorf("unsupported user field %q, supported fields: %s", field, strings.Join(SupportedUserFields, ","))
+		}
+	}
+	return nil
+}
+
+// fields returns value of fields param: first and last names, extra fields and requested user fields.
+func (client *VKClient) fields(extra ...string) string {
+	fields := append([]string{"first_name", "last_name"}, extra...)
+	return strings.Join(append(fields, client.userFields...), ",")
+}
@@ -1,17 +1,18 @@
 package vkutils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	f "github.com/rprtr258/go-flow/fun"
 	r "github.com/rprtr258/go-flow/result"
-	s "github.com/rprtr258/go-flow/stream"
 )
 
 // vk api constants
@@ -21,54 +22,37 @@ const (
 	wallGetCommentsPageSize   = PageSize(100)
 	getLikesPageSize          = PageSize(1000)
 	usersGetFollowersPageSize = PageSize(1000)
-	apiURL                    = "https://api.vk.com/method/"
-)
-
-// vk api error codes
-const (
-	tooManyRequests        = 6
-	accessDenied           = 15
-	userWasDeletedOrBanned = 18
-	profileIsPrivate       = 30
+	pollsGetVotersPageSize    = PageSize(1000)
+	// DefaultAPIURL is base url of VK api methods.
+	DefaultAPIURL = "https://api.vk.com/method/"
 )
 
 // application constants
 const (
 	apiVersion        = "5.131"
 	apiRequestRetries = 100
-	waitTimeToRetry   = time.Millisecond * 500
 )
 
-// UserID is id of some user if positive or group if negative.
-type UserID int
-
-type User struct {
-	ID         UserID `json:"id"`
-	FirstName  string `json:"first_name"`
-	SecondName string `json:"last_name"`
-}
-
-// UserList is a list of users from VK api.
-type UserList struct {
+// ItemList is a page of list of items from VK api.
+type ItemList[A any] struct {
 	Response struct {
-		Count uint   `json:"count"`
-		Items []User `json:"items"`
+		Count uint `json:"count"`
+		Items []A  `json:"items"`
 	} `json:"response"`
 }
 
+// UserList is a list of users from VK api.
+type UserList = ItemList[User]
+
 // PostID is pair of post author ID and post index.
 type PostID struct {
-	OwnerID UserID `json:"owner_id"`
-	ID      uint   `json:"id"`
+	OwnerID OwnerID `json:"owner_id"`
+	ID      uint    `json:"id"`
 }
 
-// Post is post on some user or group wall.
-type Post struct {
-	Owner       UserID   `json:"owner_id"`
-	ID          uint     `json:"id"`
-	Date        uint     `json:"date"`
-	Text        string   `json:"text"`
-	CopyHistory []PostID `json:"copy_history"`
+// URL returns link to post.
+func (id PostID) URL() string {
+	return fmt.Sprintf("https://vk.com/wall%d_%d", id.OwnerID, id.ID)
 }
 
 type wallPostsResponse struct {
@@ -87,83 +71,145 @@ type WallGetByIDResponse struct {
 	} `json:"response"`
 }
 
-type GroupsGetByIDResponse struct {
-	Response []struct {
-		ID int `json:"id"`
-	} `json:"response"`
-}
-
-type GetCommentsResponse struct {
-	Response struct {
-		Count uint `json:"count"`
-		Items []struct {
-			ID       uint   `json:"id"`
-			AuthorID UserID `json:"from_id"`
-			Thread   struct {
-				Count uint `json:"count"`
-			} `json:"thread"`
-		} `json:"items"`
-		Profiles []struct {
-			ID        UserID `json:"id"`
-			FirstName string `json:"first_name"`
-			LastName  string `json:"last_name"`
-		} `json:"profiles"`
-		Groups []struct {
-			// unsigned because api authors decided it would be funny
-			ID   uint   `json:"id"`
-			Name string `json:"name"`
-		} `json:"groups"`
-	} `json:"response"`
-}
-
 // VKClient is a client to VK api.
 type VKClient struct {
-	accessToken       string
+	accessTokens      []string
+	rps               float64
+	tokens            *tokenPool
+	apiURL            string
 	client            http.Client
-	logAPICalls       bool
+	executeBatchSize  int
+	batcher           *batcher
+	cache             *DiskCache
+	offline           bool
+	recorder          *CassetteRecorder
+	player            *CassettePlayer
+	logger            *slog.Logger
+	captchaSolver     CaptchaSolver
+	retryPolicy       RetryPolicy
+	userFields        []string
 	RepostSearchLimit uint
 }
 
-type postHiddenError struct {
-	ownerID UserID
-	postID  uint
+// ClientOption configures VKClient on creation.
+type ClientOption func(*VKClient)
+
+// WithAPIURL makes client send requests to given base url instead of DefaultAPIURL.
+// Method name is appended to it, so it should end with slash.
+func WithAPIURL(apiURL string) ClientOption {
+	return func(client *VKClient) {
+		client.apiURL = apiURL
+	}
+}
+
+// WithHTTPClient makes client use given http client.
+func WithHTTPClient(httpClient http.Client) ClientOption {
+	return func(client *VKClient) {
+		client.client = httpClient
+	}
 }
 
-func (p postHiddenError) Error() string {
-	return fmt.Sprintf("Post %d_%d is hidden", p.ownerID, p.postID)
+// WithRateLimit limits requests per second sent with each access token, see DefaultRPS.
+// Non-positive rps disables limiting.
+func WithRateLimit(rps float64) ClientOption {
+	return func(client *VKClient) {
+		client.rps = rps
+	}
 }
 
-// VkError is vk api error.
-type VkError struct {
-	Code    uint   `json:"error_code"`
-	Message string `json:"error_msg"`
+// WithTokens adds access tokens to use besides one given to NewVKClient. Requests are spread
+// between tokens, each limited by its own rate limiter. Tokens hitting flood control or rate limit
+// are not used for a while and invalid tokens are not used at all.
+func WithTokens(accessTokens ...string) ClientOption {
+	return func(client *VKClient) {
+		client.accessTokens = append(client.accessTokens, accessTokens...)
+	}
+}
+
+// WithExecuteBatching makes client merge up to size concurrent calls into single execute request,
+// see DefaultExecuteBatchSize. Size less than two disables batching.
+func WithExecuteBatching(size int) ClientOption {
+	return func(client *VKClient) {
+		client.executeBatchSize = size
+	}
+}
+
+// WithCache makes client store responses in cache and reuse them while they are fresh.
+// In offline mode client never sends requests and serves all responses from cache, even stale ones.
+func WithCache(cache *DiskCache, offline bool) ClientOption {
+	return func(client *VKClient) {
+		client.cache = cache
+		client.offline = offline
+	}
+}
+
+// WithRecorder makes client write all requests and responses to cassette.
+// Execute batching is disabled so that every call is recorded separately.
+func WithRecorder(recorder *CassetteRecorder) ClientOption {
+	return func(client *VKClient) {
+		client.recorder = recorder
+	}
+}
+
+// WithReplay makes client serve all responses from cassette instead of api.
+// Execute batching is disabled as calls are recorded separately.
+func WithReplay(player *CassettePlayer) ClientOption {
+	return func(client *VKClient) {
+		client.player = player
+	}
 }
 
-func (err VkError) Error() string {
-	return fmt.Sprintf("Error(%d) %s", err.Code, err.Message)
+// WithLogger makes client log api calls and errors to logger. Api calls are logged on debug level.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(client *VKClient) {
+		client.logger = logger
+	}
 }
 
-type VkErrorResponse struct {
-	Err VkError `json:"error"`
+// WithCaptchaSolver makes client solve captcha VK asks for and retry request with answer.
+// Without solver request asking for captcha fails with ErrCaptchaNeeded.
+func WithCaptchaSolver(solver CaptchaSolver) ClientOption {
+	return func(client *VKClient) {
+		client.captchaSolver = solver
+	}
 }
 
-type ApiCallError struct {
-	vkError VkError
-	method  string
-	params  url.Values
+// WithRetryPolicy sets how requests failed due to network, server or internal VK errors are retried,
+// see DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(client *VKClient) {
+		client.retryPolicy = policy
+	}
 }
 
-func (err ApiCallError) Error() string {
-	return fmt.Sprintf("error while %s(%v): %v", err.method, err.params, err.vkError)
+// WithUserFields makes client request given profile fields in addition to first and last names,
+// see SupportedUserFields.
+func WithUserFields(fields ...string) ClientOption {
+	return func(client *VKClient) {
+		client.userFields = fields
+	}
 }
 
 // NewVKClient creates new VKClient.
-func NewVKClient(accessToken string, verbose bool) VKClient {
-	return VKClient{
-		accessToken: accessToken,
-		client:      *http.DefaultClient,
-		logAPICalls: verbose,
+func NewVKClient(accessToken string, opts ...ClientOption) VKClient {
+	client := VKClient{
+		accessTokens:     []string{accessToken},
+		rps:              DefaultRPS,
+		apiURL:           DefaultAPIURL,
+		client:           *http.DefaultClient,
+		executeBatchSize: DefaultExecuteBatchSize,
+		logger:           slog.Default(),
+		retryPolicy:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(&client)
 	}
+	client.tokens = newTokenPool(client.accessTokens, client.rps, client.retryPolicy.MaxElapsed, client.logger)
+	if client.recorder != nil || client.player != nil {
+		client.executeBatchSize = 0
+	}
+	client.batcher = newBatcher(client, client.executeBatchSize)
+	return client
 }
 
 func jsonUnmarshal[J any](body []byte) r.Result[J] {
@@ -177,251 +223,375 @@ func jsonUnmarshal[J any](body []byte) r.Result[J] {
 	})
 }
 
-func (client *VKClient) apiRequest(method string, params url.Values, params2 ...string) r.Result[[]byte] {
+func (client *VKClient) apiRequest(ctx context.Context, method string, params url.Values, params2 ...string) r.Result[[]byte] {
+	params = cloneValues(params)
 	for i := 0; i < len(params2); i += 2 {
 		params.Set(params2[i], params2[i+1])
 	}
-	methodURL := fmt.Sprintf("%s%s", apiURL, method)
-	req, err := http.NewRequest(http.MethodGet, methodURL, nil)
-	if err != nil {
-		return r.Err[[]byte](err)
-	}
 
-	reqParams := make(url.Values)
-	reqParams.Add("v", apiVersion)
-	reqParams.Add("access_token", client.accessToken)
-	for k, v := range params {
-		reqParams.Add(k, v[0])
+	requestStart := time.Now()
+	failures := 0
+	retry := func(logger *slog.Logger, err error) bool {
+		if !isRetryable(err) {
+			return false
+		}
+		failures++
+		if !client.retryPolicy.wait(ctx, failures, requestStart) {
+			return false
+		}
+		logger.Warn("retrying failed request", "error", err, "failures", failures)
+		return true
 	}
-	req.URL.RawQuery = reqParams.Encode()
 
+	// lastErr is kept to tell why request failed when retries are exhausted
+	var lastErr error
 	timeLimitTries := 0
-	var resp *http.Response
-	for timeLimitTries < apiRequestRetries {
-		resp, err = client.client.Do(req)
-		if err != nil {
-			return r.Err[[]byte](err)
-		}
-		defer func() {
-			if err := resp.Body.Close(); err != nil {
-				log.Println(err)
+	for ; timeLimitTries < apiRequestRetries; timeLimitTries++ {
+		start := time.Now()
+		bodyResult := client.roundTrip(ctx, method, params)
+		logger := client.logger.With(
+			"method", method,
+			"params", params,
+			"attempt", timeLimitTries+1,
+			"duration", time.Since(start),
+		)
+		if bodyResult.IsErr() {
+			lastErr = bodyResult.UnwrapErr()
+			logger.Debug("api request failed", "error", lastErr)
+			if retry(logger, bodyResult.UnwrapErr()) {
+				continue
 			}
-		}()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return r.Err[[]byte](err)
+			return bodyResult
 		}
-		client.mylog("VK API REQUEST", map[string]any{
-			"method":   method,
-			"params":   params,
-			"response": string(body),
-		})
+		body := bodyResult.Unwrap()
+		logger.Debug("api request", "response", string(body))
 		// move out parsing response
 		errr := jsonUnmarshal[VkErrorResponse](body)
 		if errr.IsErr() {
-			return r.Err[[]byte](err)
+			return r.Err[[]byte](errr.UnwrapErr())
 		}
 		v := errr.Unwrap()
 		if v.Err.Code != 0 {
+			lastErr = ApiCallError{
+				vkError: v.Err,
+				method:  method,
+				params:  cloneValues(params),
+			}
 			switch {
-			case v.Err.Code == tooManyRequests:
-				client.mylog("TOO MANY REQUESTS", map[string]any{
-					"method": method,
-					"params": params,
-				})
-				time.Sleep(waitTimeToRetry)
+			case v.Err.Code == ErrTooManyRequests:
+				// token used is disabled for a while, so next attempt waits for it or goes with other token
+				if client.retryPolicy.expired(requestStart, 0) {
+					return r.Err[[]byte](lastErr)
+				}
+				logger.Warn("too many requests", "error_code", v.Err.Code)
+				continue
+			case (v.Err.Code.IsRateLimit() || v.Err.Code == ErrAuthFailed) && client.tokens.hasUsable():
+				logger.Warn("retrying with other access token", "error_code", v.Err.Code)
+				continue
+			case v.Err.Code == ErrCaptchaNeeded && client.captchaSolver != nil:
+				logger.Warn("captcha needed", "error_code", v.Err.Code, "captcha_img", v.Err.CaptchaImg)
+				answer := client.captchaSolver.SolveCaptcha(ctx, v.Err.CaptchaSID, v.Err.CaptchaImg)
+				if answer.IsSuccess() {
+					params.Set("captcha_sid", v.Err.CaptchaSID)
+					params.Set("captcha_key", answer.Unwrap())
+					continue
+				}
+				logger.Error("captcha is not solved", "error", answer.UnwrapErr())
+				return r.Err[[]byte](lastErr)
+			case retry(logger, v.Err):
 				continue
 			default:
-				return r.Err[[]byte](ApiCallError{
-					vkError: v.Err,
-					method:  method,
-					params:  params,
-				})
+				logger.Debug("api error", "error_code", v.Err.Code, "error", v.Err.Message)
+				return r.Err[[]byte](lastErr)
 			}
 		}
 		return r.Success(body)
 	}
-	return r.Err[[]byte](fmt.Errorf("%s(%v) = Timeout", method, params))
+	return r.Err[[]byte](fmt.Errorf("%s(%v) = Timeout: %w", method, params, lastErr))
 }
 
-type pagedImpl[A any] struct {
-	Pager[A]
+// roundTrip gets response either from cassette in replay mode or from cache or api,
+// recording it if needed.
+func (client *VKClient) roundTrip(ctx context.Context, method string, params url.Values) r.Result[[]byte] {
+	if client.player != nil {
+		return client.player.play(method, params)
+	}
+	body := client.cachedFetch(ctx, method, params)
+	if client.recorder != nil && body.IsSuccess() {
+		if err := client.recorder.record(method, params, body.Unwrap()); err != nil {
+			client.logger.Error("error recording response", "method", method, "params", params, "error", err)
+		}
+	}
+	return body
 }
 
-func (xs *pagedImpl[A]) Next() f.Option[[]A] {
-	pageResult := xs.NextPage()
-	if pageResult.IsErr() {
-		log.Printf("ERROR WHILE GETTING PAGE in %T(%[1]v): %v\n", xs.Pager, pageResult.UnwrapErr())
-		return f.None[[]A]()
+// cachedFetch serves response from cache if possible, fetching and storing it otherwise.
+func (client *VKClient) cachedFetch(ctx context.Context, method string, params url.Values) r.Result[[]byte] {
+	if client.cache == nil || method == "execute" {
+		return client.fetch(ctx, method, params)
+	}
+	if cached := client.cache.Get(method, params, client.offline); cached.IsSome() {
+		return r.Success(cached.Unwrap())
+	}
+	if client.offline {
+		return r.Err[[]byte](ErrNotCached{Method: method, Params: params})
+	}
+	body := client.fetch(ctx, method, params)
+	if body.IsSuccess() && isCacheable(body.Unwrap()) {
+		if err := client.cache.Set(method, params, body.Unwrap()); err != nil {
+			client.logger.Error("error caching response", "method", method, "params", params, "error", err)
+		}
+	}
+	return body
+}
+
+// fetch sends single http request to api method.
+func (client *VKClient) fetch(ctx context.Context, method string, params url.Values) r.Result[[]byte] {
+	methodURL := fmt.Sprintf("%s%s", client.apiURL, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, methodURL, nil)
+	if err != nil {
+		return r.Err[[]byte](err)
+	}
+
+	reqParams := make(url.Values)
+	reqParams.Add("v", apiVersion)
+	for k, v := range params {
+		reqParams.Add(k, v[0])
+	}
+
+	tok, err := client.tokens.acquire(ctx)
+	if err != nil {
+		return r.Err[[]byte](err)
+	}
+	reqParams.Add("access_token", tok.token)
+	req.URL.RawQuery = reqParams.Encode()
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return r.Err[[]byte](err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			client.logger.Error("error closing response body", "method", method, "error", err)
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return r.Err[[]byte](err)
 	}
-	return pageResult.Unwrap()
+	if resp.StatusCode != http.StatusOK {
+		return r.Err[[]byte](HTTPStatusError{StatusCode: resp.StatusCode})
+	}
+	if errResp := jsonUnmarshal[VkErrorResponse](body); errResp.IsSuccess() {
+		client.tokens.report(tok, errResp.Unwrap().Err.Code)
+	}
+	return r.Success(body)
 }
 
-func getPaged[A any](pager Pager[A]) s.Stream[A] {
-	return s.Paged[A](&pagedImpl[A]{pager})
+func getPaged[A any](pager Pager[A]) ErrStream[A] {
+	return &pagedStream[A]{pager: pager}
 }
 
 type Pager[A any] interface {
 	NextPage() r.Result[f.Option[[]A]]
 }
 
-type userListPager struct {
+type listPager[A any] struct {
+	ctx       context.Context
 	client    *VKClient
 	method    string
 	urlParams url.Values
 	offset    uint
 	total     f.Option[uint]
 	pageSize  PageSize
+	// parallelPages is how many pages to request at once after total is known,
+	// so that they are sent in single execute request
+	parallelPages uint
+	fetched       [][]A
 }
 
-func (pager *userListPager) NextPage() r.Result[f.Option[[]User]] {
+func (pager *listPager[A]) NextPage() r.Result[f.Option[[]A]] {
+	if len(pager.fetched) > 0 {
+		page := pager.fetched[0]
+		pager.fetched = pager.fetched[1:]
+		return r.Success(f.Some(page))
+	}
 	if pager.total.IsSome() && pager.offset >= pager.total.Unwrap() {
-		return r.Success(f.None[[]User]())
+		return r.Success(f.None[[]A]())
+	}
+	if pager.total.IsSome() && pager.parallelPages > 1 {
+		return pager.fetchParallel()
 	}
-	userList := r.FlatMap(
-		pager.client.apiRequest(pager.method, pager.urlParams, "offset", fmt.Sprint(pager.offset)),
-		jsonUnmarshal[UserList],
+	itemList := r.FlatMap(
+		pager.client.apiRequest(pager.ctx, pager.method, pager.urlParams, "offset", fmt.Sprint(pager.offset)),
+		jsonUnmarshal[ItemList[A]],
 	)
 	return r.Map(
-		userList,
-		func(ul UserList) f.Option[[]User] {
+		itemList,
+		func(l ItemList[A]) f.Option[[]A] {
 			pager.offset += uint(pager.pageSize)
-			pager.total = f.Some(ul.Response.Count)
-			return f.Some(ul.Response.Items)
+			pager.total = f.Some(l.Response.Count)
+			return f.Some(l.Response.Items)
 		},
 	)
 }
 
-func (client *VKClient) getUserList(method string, params url.Values, pageSize PageSize) s.Stream[User] {
+// fetchParallel requests next pages concurrently and returns first of them.
+func (pager *listPager[A]) fetchParallel() r.Result[f.Option[[]A]] {
+	offsets := make([]uint, 0, pager.parallelPages)
+	for offset := pager.offset; offset < pager.total.Unwrap() && uint(len(offsets)) < pager.parallelPages; offset += uint(pager.pageSize) {
+		offsets = append(offsets, offset)
+	}
+	pages := make([]r.Result[ItemList[A]], len(offsets))
+	var wg sync.WaitGroup
+	for i, offset := range offsets {
+		wg.Add(1)
+		go func(i int, offset uint) {
+			defer wg.Done()
+			pages[i] = r.FlatMap(
+				pager.client.batchRequest(pager.ctx, pager.method, pager.urlParams, "offset", fmt.Sprint(offset)),
+				jsonUnmarshal[ItemList[A]],
+			)
+		}(i, offset)
+	}
+	wg.Wait()
+	for _, page := range pages {
+		if page.IsErr() {
+			return r.Err[f.Option[[]A]](page.UnwrapErr())
+		}
+		pager.offset += uint(pager.pageSize)
+		pager.fetched = append(pager.fetched, page.Unwrap().Response.Items)
+	}
+	return pager.NextPage()
+}
+
+// getList streams items of list method, requesting parallelPages pages at once.
+func getList[A any](ctx context.Context, client *VKClient, method string, params url.Values, pageSize PageSize, parallelPages uint) ErrStream[A] {
 	params.Set("count", fmt.Sprint(pageSize))
-	return getPaged[User](&userListPager{
-		offset:    0,
-		total:     f.None[uint](),
-		client:    client,
-		method:    method,
-		urlParams: params,
-		pageSize:  pageSize,
+	return getPaged[A](&listPager[A]{
+		ctx:           ctx,
+		offset:        0,
+		total:         f.None[uint](),
+		client:        client,
+		method:        method,
+		urlParams:     params,
+		pageSize:      pageSize,
+		parallelPages: parallelPages,
+		fetched:       nil,
 	})
 }
 
-func (client *VKClient) getGroupMembers(groupID UserID) s.Stream[User] {
-	return client.getUserList("groups.getMembers", MakeUrlValues(map[string]any{
-		"group_id": -groupID,
-		"fields":   "first_name,last_name",
-	}), groupsGetMembersPageSize)
+func (client *VKClient) getUserList(ctx context.Context, method string, params url.Values, pageSize PageSize) ErrStream[User] {
+	return getList[User](ctx, client, method, params, pageSize, 1)
 }
 
-func (client *VKClient) getFriends(userID UserID) s.Stream[User] {
-	return client.getUserList("friends.get", MakeUrlValues(map[string]any{
+// getGroupMembers streams members of group. Filter, if not empty, limits members to
+// managers or friends of token owner.
+func (client *VKClient) getGroupMembers(ctx context.Context, groupID GroupID, filter string) ErrStream[User] {
+	params := MakeUrlValues(map[string]any{
+		"group_id": groupID,
+		"fields":   client.fields(),
+	})
+	if filter != "" {
+		params.Set("filter", filter)
+	}
+	return getList[User](ctx, client, "groups.getMembers", params, groupsGetMembersPageSize, uint(client.executeBatchSize))
+}
+
+func (client *VKClient) getFriends(ctx context.Context, userID UserID) ErrStream[User] {
+	return client.getUserList(ctx, "friends.get", MakeUrlValues(map[string]any{
 		"user_id": userID,
-		"fields":  "first_name,last_name",
+		"fields":  client.fields(),
 	}), getFriendsPageSize)
 }
 
-func (client *VKClient) getLikes(postID PostID) s.Stream[User] {
-	return client.getUserList("likes.getList", MakeUrlValues(map[string]any{
-		"type":     "post",
-		"owner_id": postID.OwnerID,
-		"item_id":  postID.ID,
+// likeItem is user or group in extended list of likers.
+type likeItem struct {
+	Type string `json:"type"`
+	User
+	Name string `json:"name"`
+}
+
+func (item likeItem) owner() Owner {
+	if item.Type == "group" || item.Type == "page" || item.Type == "event" {
+		return GroupOwner(Group{
+			ID:          GroupID(item.ID),
+			Name:        item.Name,
+			ScreenName:  item.ScreenName,
+			Deactivated: item.Deactivated,
+		})
+	}
+	return UserOwner(item.User)
+}
+
+// getLikes streams likers of item of given type: post, photo, video or comment.
+func (client *VKClient) getLikes(ctx context.Context, itemType string, itemID PostID) ErrStream[Owner] {
+	return mapErr(getList[likeItem](ctx, client, "likes.getList", MakeUrlValues(map[string]any{
+		"type":     itemType,
+		"owner_id": itemID.OwnerID,
+		"item_id":  itemID.ID,
 		"skip_own": "0",
 		"extended": "1",
-	}), getLikesPageSize)
+	}), getLikesPageSize, 1), likeItem.owner)
 }
 
-func (client *VKClient) getFollowers(userID UserID) s.Stream[User] {
-	return client.getUserList("users.getFollowers", MakeUrlValues(map[string]any{
+func (client *VKClient) getFollowers(ctx context.Context, userID UserID) ErrStream[User] {
+	return client.getUserList(ctx, "users.getFollowers", MakeUrlValues(map[string]any{
 		"user_id": userID,
-		"fields":  "first_name,last_name",
+		"fields":  client.fields(),
 	}), usersGetFollowersPageSize)
 }
 
-func (client *VKClient) GetComments(postID PostID) s.Stream[User] {
-	groupNames := map[UserID]string{}
-	userNames := map[UserID]f.Pair[string, string]{}
-	res := f.NewSet[UserID]()
-	total := f.None[uint]()
-	totalSeen := uint(0)
-	offset := uint(0)
-	params := MakeUrlValues(map[string]any{
-		"owner_id": postID.OwnerID,
-		"post_id":  postID.ID,
-		"extended": 1,
-		"fields":   "first_name,last_name,name",
-		"count":    wallGetCommentsPageSize,
-	})
-	commentsThreadsToCheck := make([]f.Pair[uint, uint], 0)
-	for total.IsNone() || offset < total.Unwrap() {
-		kk := client.apiRequest("wall.getComments", params, "offset", fmt.Sprint(offset))
-		k := r.FlatMap(kk, jsonUnmarshal[GetCommentsResponse])
-		if k.IsErr() {
-			log.Println(k.UnwrapErr())
-			break
-		}
-		k0 := k.Unwrap()
-		total = f.Some(k0.Response.Count)
-		totalSeen += uint(len(k0.Response.Items))
-		offset += uint(wallGetCommentsPageSize)
-		for _, item := range k0.Response.Items {
-			res.Add(item.AuthorID)
-			if item.Thread.Count > 0 {
-				commentsThreadsToCheck = append(commentsThreadsToCheck, f.NewPair(item.ID, item.Thread.Count))
-			}
-		}
-		for _, profile := range k0.Response.Profiles {
-			userNames[profile.ID] = f.NewPair(profile.FirstName, profile.LastName)
-		}
-		for _, group := range k0.Response.Groups {
-			groupNames[-UserID(group.ID)] = group.Name
-		}
-	}
-	for _, commentIDAndThreadSize := range commentsThreadsToCheck {
-		for offset := uint(0); offset < commentIDAndThreadSize.Right; offset++ {
-			kk := client.apiRequest("wall.getComments", params, "comment_id", fmt.Sprint(commentIDAndThreadSize.Left), "offset", fmt.Sprint(offset))
-			k := r.FlatMap(kk, jsonUnmarshal[GetCommentsResponse])
-			if k.IsErr() {
-				log.Println(k.UnwrapErr())
-				break
-			}
-			k0 := k.Unwrap()
-			for _, item := range k0.Response.Items {
-				res.Add(item.AuthorID)
-			}
-			for _, profile := range k0.Response.Profiles {
-				userNames[profile.ID] = f.NewPair(profile.FirstName, profile.LastName)
-			}
-			for _, group := range k0.Response.Groups {
-				groupNames[-UserID(group.ID)] = group.Name
-			}
-		}
+type mutualFriendsResponse struct {
+	Response []UserID `json:"response"`
+}
+
+// mutualFriendsPager gets all mutual friends as single page, friends.getMutual is not paged.
+type mutualFriendsPager struct {
+	ctx    context.Context
+	client *VKClient
+	params url.Values
+	done   bool
+}
+
+func (pager *mutualFriendsPager) NextPage() r.Result[f.Option[[]User]] {
+	if pager.done {
+		return r.Success(f.None[[]User]())
 	}
-	return s.Map(
-		s.FromSet(res),
-		func(userID UserID) User {
-			if userID < 0 {
-				return User{
-					ID:         userID,
-					FirstName:  groupNames[userID],
-					SecondName: "_GROUP",
-				}
-			}
-			userName := userNames[userID]
-			return User{
-				ID:         userID,
-				FirstName:  userName.Left,
-				SecondName: userName.Right,
+	pager.done = true
+	return r.Map(
+		r.FlatMap(pager.client.apiRequest(pager.ctx, "friends.getMutual", pager.params), jsonUnmarshal[mutualFriendsResponse]),
+		func(friends mutualFriendsResponse) f.Option[[]User] {
+			users := make([]User, len(friends.Response))
+			for i, id := range friends.Response {
+				users[i] = User{ID: id}
 			}
+			return f.Some(users)
 		},
 	)
 }
 
-func (client *VKClient) getWallPosts(params url.Values, params2 ...string) r.Result[WallPosts] {
-	body := client.apiRequest("wall.get", params, params2...)
+// getMutualFriends streams friends both users have, only ids of friends are known.
+// Friends are requested on first pull from stream.
+func (client *VKClient) getMutualFriends(ctx context.Context, userID, targetID UserID) ErrStream[User] {
+	return getPaged[User](&mutualFriendsPager{
+		ctx:    ctx,
+		client: client,
+		params: MakeUrlValues(map[string]any{
+			"source_uid": userID,
+			"target_uid": targetID,
+		}),
+	})
+}
+
+func (client *VKClient) getWallPosts(ctx context.Context, params url.Values, params2 ...string) r.Result[WallPosts] {
+	body := client.batchRequest(ctx, "wall.get", params, params2...)
 	return r.FlatMap(body, jsonUnmarshal[WallPosts])
 }
 
 // getPostTime returns post creation time as Unix uint.
-func (client *VKClient) getPostTime(postID PostID) r.Result[uint] {
-	body := client.apiRequest("wall.getById", MakeUrlValues(map[string]any{
+func (client *VKClient) getPostTime(ctx context.Context, postID PostID) r.Result[uint] {
+	body := client.apiRequest(ctx, "wall.getById", MakeUrlValues(map[string]any{
 		"posts": fmt.Sprintf("%d_%d", postID.OwnerID, postID.ID),
 	}))
 	userList := r.FlatMap(body, jsonUnmarshal[WallGetByIDResponse])
@@ -429,21 +599,39 @@ func (client *VKClient) getPostTime(postID PostID) r.Result[uint] {
 		userList,
 		func(v WallGetByIDResponse) r.Result[uint] {
 			if len(v.Response) != 1 {
-				return r.Err[uint](postHiddenError{postID.OwnerID, postID.ID})
+				return r.Err[uint](PostHiddenError{postID})
 			}
 			return r.Success(v.Response[0].Date)
 		},
 	)
 }
 
-func (client *VKClient) getGroupID(groupName string) r.Result[UserID] {
-	vR := r.FlatMap(
-		client.apiRequest("groups.getById", MakeUrlValues(map[string]any{
-			"group_id": groupName,
+// ScreenName is object short name points to.
+type ScreenName struct {
+	// Type is one of user, group, page, event or application.
+	Type     string `json:"type"`
+	ObjectID uint   `json:"object_id"`
+}
+
+type resolveScreenNameResponse struct {
+	Response json.RawMessage `json:"response"`
+}
+
+// ResolveScreenName finds user, group or application by its short name, e.g. "durov".
+func (client *VKClient) ResolveScreenName(ctx context.Context, name string) r.Result[ScreenName] {
+	resp := r.FlatMap(
+		client.apiRequest(ctx, "utils.resolveScreenName", MakeUrlValues(map[string]any{
+			"screen_name": name,
 		})),
-		jsonUnmarshal[GroupsGetByIDResponse],
+		jsonUnmarshal[resolveScreenNameResponse],
 	)
-	return r.Map(vR, func(v GroupsGetByIDResponse) UserID { return UserID(-v.Response[0].ID) })
+	return r.FlatMap(resp, func(resp resolveScreenNameResponse) r.Result[ScreenName] {
+		// api responds with empty list if name is not taken
+		if string(resp.Response) == "[]" {
+			return r.Err[ScreenName](ScreenNameNotFoundError{Name: name})
+		}
+		return jsonUnmarshal[ScreenName](resp.Response)
+	})
 }
 
 func MakeUrlValues(kvs map[string]any) url.Values {
@@ -453,13 +641,3 @@ func MakeUrlValues(kvs map[string]any) url.Values {
 	}
 	return res
 }
-
-func (client *VKClient) mylog(message string, data map[string]any) {
-	if !client.logAPICalls {
-		return
-	}
-	log.Print(message, ": ")
-	for k, v := range data {
-		log.Printf("%s=%+v ", k, v)
-	}
-}
@@ -0,0 +1,115 @@
+package vkutils_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	s "github.com/rprtr258/go-flow/stream"
+	vk "github.com/rprtr258/vk-utils/pkg"
+	"github.com/rprtr258/vk-utils/pkg/vktest"
+)
+
+// wall makes n posts of owner from new to old.
+func wall(ownerID vk.OwnerID, n int) []vk.Post {
+	posts := make([]vk.Post, n)
+	for i := range posts {
+		posts[i] = vk.Post{Owner: ownerID, ID: uint(n - i), Date: uint(1000 * (n - i))}
+	}
+	return posts
+}
+
+// getPosts fetches all posts of wall, failing test on error.
+func getPosts(t *testing.T, client vk.VKClient, ownerID vk.OwnerID) []vk.Post {
+	t.Helper()
+	stream := vk.GetPosts(context.Background(), client, ownerID)
+	posts := s.CollectToSlice[vk.Post](stream)
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return posts
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls: map[vk.OwnerID][]vk.Post{1: wall(1, 1)},
+		Errors: []vktest.ErrorRule{
+			{Method: "wall.get", HTTPStatus: http.StatusBadGateway, Times: 2},
+			{Method: "wall.get", Code: vk.ErrInternal, Times: 1},
+		},
+	})
+	defer srv.Close()
+
+	client := srv.Client(vk.WithRetryPolicy(vk.RetryPolicy{
+		MaxAttempts: 5,
+		InitialWait: time.Millisecond,
+		MaxWait:     time.Millisecond,
+		Multiplier:  2,
+	}))
+	if posts := getPosts(t, client, 1); len(posts) != 1 {
+		t.Errorf("expected single post, got %d", len(posts))
+	}
+	if calls := srv.Calls("wall.get"); calls != 4 {
+		t.Errorf("expected 4 attempts, got %d", calls)
+	}
+}
+
+func TestTooManyRequestsWaits(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls:  map[vk.OwnerID][]vk.Post{1: wall(1, 1)},
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrTooManyRequests, Times: 2}},
+	})
+	defer srv.Close()
+
+	start := time.Now()
+	if posts := getPosts(t, srv.Client(), 1); len(posts) != 1 {
+		t.Errorf("expected single post, got %d", len(posts))
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("expected retries to wait even without rate limit, took %v", elapsed)
+	}
+	if calls := srv.Calls("wall.get"); calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestTooManyRequestsGivesUp(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls:  map[vk.OwnerID][]vk.Post{1: wall(1, 1)},
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrTooManyRequests}},
+	})
+	defer srv.Close()
+
+	policy := vk.DefaultRetryPolicy
+	policy.MaxElapsed = 1500 * time.Millisecond
+	stream := vk.GetPosts(context.Background(), srv.Client(vk.WithRetryPolicy(policy)), 1)
+	s.CollectToSlice[vk.Post](stream)
+	if err := stream.Err(); !errors.Is(err, vk.ErrTooManyRequests) {
+		t.Errorf("expected too many requests error, got %v", err)
+	}
+	// every attempt waits for token cooldown, third one runs out of retry time
+	if calls := srv.Calls("wall.get"); calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestInvalidTokenIsSkipped(t *testing.T) {
+	srv := vktest.NewServer(vktest.Fixtures{
+		Walls:  map[vk.OwnerID][]vk.Post{1: wall(1, 1)},
+		Errors: []vktest.ErrorRule{{Method: "wall.get", Code: vk.ErrAuthFailed, Params: map[string]string{"access_token": "test-token"}}},
+	})
+	defer srv.Close()
+
+	client := srv.Client(vk.WithTokens("other-token"))
+	for i := 0; i < 3; i++ {
+		if posts := getPosts(t, client, 1); len(posts) != 1 {
+			t.Errorf("expected single post, got %d", len(posts))
+		}
+	}
+	// invalid token is tried once, other requests go with other token
+	if calls := srv.Calls("wall.get"); calls != 4 {
+		t.Errorf("expected 4 calls, got %d", calls)
+	}
+}
@@ -0,0 +1,622 @@
+// Package vktest provides in-process fake VK api server serving responses from fixtures.
+// It is meant for writing deterministic tests of code built on vkutils.VKClient.
+package vktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	vk "github.com/rprtr258/vk-utils/pkg"
+)
+
+// Comment is comment fixture. Replies are served as comment thread.
+type Comment struct {
+	ID             uint       `json:"id"`
+	FromID         vk.OwnerID `json:"from_id"`
+	Text           string     `json:"text"`
+	Date           uint       `json:"date"`
+	Likes          vk.Counter `json:"likes"`
+	ReplyToComment uint       `json:"reply_to_comment,omitempty"`
+	Replies        []Comment  `json:"-"`
+}
+
+// ErrorRule makes server respond with error on matching requests.
+type ErrorRule struct {
+	// Method is api method name to fail, e.g. "wall.get".
+	Method string
+	// Params must all be present in request with given values, if set.
+	Params map[string]string
+	// Code is vk api error code to respond with.
+	Code vk.ErrorCode
+	// HTTPStatus, if set, makes server respond with this http status instead of vk api error.
+	HTTPStatus int
+	// Times is how many times to fail, zero means always.
+	Times int
+}
+
+// Fixtures is data fake server serves.
+type Fixtures struct {
+	// Users are profiles, used to fill names in responses.
+	Users map[vk.UserID]vk.User
+	// Groups are group profiles.
+	Groups map[vk.GroupID]vk.Group
+	// Walls are posts on user or group walls, newest first.
+	Walls map[vk.OwnerID][]vk.Post
+	// GroupMembers are group member ids by group id.
+	GroupMembers map[vk.GroupID][]vk.UserID
+	// Friends are friend ids by user id.
+	Friends map[vk.UserID][]vk.UserID
+	// Likes are ids of users and groups liked post.
+	Likes map[vk.PostID][]vk.OwnerID
+	// ItemLikes are ids of users and groups liked photo, video or comment by item type.
+	ItemLikes map[string]map[vk.PostID][]vk.OwnerID
+	// Managers are group manager ids by group id.
+	Managers map[vk.GroupID][]vk.UserID
+	// Me is id of token owner, used to find friends among group members.
+	Me vk.UserID
+	// PollVoters are ids of users voted for poll answer.
+	PollVoters map[vk.PollAnswerID][]vk.UserID
+	// Comments are top level comments by post.
+	Comments map[vk.PostID][]Comment
+	// Errors are rules to fail requests with, checked in order.
+	Errors []ErrorRule
+}
+
+// Server is fake VK api server.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fixtures Fixtures
+	calls    map[string]int
+}
+
+type handler func(*Server, url.Values) (any, *vk.VkError)
+
+var handlers = map[string]handler{
+	"wall.get":                (*Server).wallGet,
+	"wall.getById":            (*Server).wallGetByID,
+	"groups.getMembers":       (*Server).groupsGetMembers,
+	"friends.get":             (*Server).friendsGet,
+	"likes.getList":           (*Server).likesGetList,
+	"wall.getComments":        (*Server).wallGetComments,
+	"groups.getById":          (*Server).groupsGetByID,
+	"users.get":               (*Server).usersGet,
+	"friends.getMutual":       (*Server).friendsGetMutual,
+	"polls.getById":           (*Server).pollsGetByID,
+	"polls.getVoters":         (*Server).pollsGetVoters,
+	"utils.resolveScreenName": (*Server).utilsResolveScreenName,
+}
+
+// NewServer starts fake VK api server. It must be closed after use.
+func NewServer(fixtures Fixtures) *Server {
+	server := &Server{
+		fixtures: fixtures,
+		calls:    map[string]int{},
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// APIURL returns base url to pass to vkutils.WithAPIURL.
+func (s *Server) APIURL() string {
+	return s.URL + "/method/"
+}
+
+// Client creates client sending requests to the server. Rate limiting is disabled unless set in opts.
+func (s *Server) Client(opts ...vk.ClientOption) vk.VKClient {
+	defaults := []vk.ClientOption{
+		vk.WithAPIURL(s.APIURL()),
+		vk.WithRateLimit(0),
+	}
+	return vk.NewVKClient("test-token", append(defaults, opts...)...)
+}
+
+// Calls returns how many times given method was called.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	method := strings.TrimPrefix(req.URL.Path, "/method/")
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, rule := s.matchRule(method, req.Form, true); rule != nil {
+		s.calls[method]++
+		http.Error(w, http.StatusText(rule.HTTPStatus), rule.HTTPStatus)
+		return
+	}
+
+	var body any
+	if method == "execute" {
+		body = s.execute(req.Form)
+	} else if response, vkErr := s.call(method, req.Form); vkErr != nil {
+		body = vk.VkErrorResponse{Err: *vkErr}
+	} else {
+		body = map[string]any{"response": response}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) call(method string, params url.Values) (any, *vk.VkError) {
+	s.calls[method]++
+	h, ok := handlers[method]
+	if !ok {
+		return nil, &vk.VkError{Code: vk.ErrUnknownMethod, Message: "Unknown method passed"}
+	}
+	if vkErr := s.matchError(method, params); vkErr != nil {
+		return nil, vkErr
+	}
+	return h(s, params)
+}
+
+type executeError struct {
+	Method  string       `json:"method"`
+	Code    vk.ErrorCode `json:"error_code"`
+	Message string       `json:"error_msg"`
+}
+
+// execute runs code of form "return [API.method({...}),...];", the only one vkutils produces.
+func (s *Server) execute(params url.Values) any {
+	s.calls["execute"]++
+	if vkErr := s.matchError("execute", params); vkErr != nil {
+		return vk.VkErrorResponse{Err: *vkErr}
+	}
+
+	code := params.Get("code")
+	if !strings.HasPrefix(code, "return [") || !strings.HasSuffix(code, "];") {
+		return vk.VkErrorResponse{Err: vk.VkError{Code: vk.ErrExecuteCompile, Message: "Unable to compile code"}}
+	}
+	code = strings.TrimSuffix(strings.TrimPrefix(code, "return ["), "];")
+
+	results := []any{}
+	errors := []executeError{}
+	for code != "" {
+		code = strings.TrimPrefix(strings.TrimPrefix(code, ","), "API.")
+		nameEnd := strings.IndexByte(code, '(')
+		if nameEnd == -1 {
+			return vk.VkErrorResponse{Err: vk.VkError{Code: vk.ErrExecuteCompile, Message: "Unable to compile code"}}
+		}
+		method := code[:nameEnd]
+		decoder := json.NewDecoder(strings.NewReader(code[nameEnd+1:]))
+		var args map[string]string
+		if err := decoder.Decode(&args); err != nil {
+			return vk.VkErrorResponse{Err: vk.VkError{Code: vk.ErrExecuteCompile, Message: "Unable to compile code: " + err.Error()}}
+		}
+		code = strings.TrimPrefix(code[nameEnd+1+int(decoder.InputOffset()):], ")")
+
+		callParams := url.Values{}
+		for k, v := range args {
+			callParams.Set(k, v)
+		}
+		if response, vkErr := s.call(method, callParams); vkErr != nil {
+			results = append(results, false)
+			errors = append(errors, executeError{
+				Method:  method,
+				Code:    vkErr.Code,
+				Message: vkErr.Message,
+			})
+		} else {
+			results = append(results, response)
+		}
+	}
+
+	res := map[string]any{"response": results}
+	if len(errors) > 0 {
+		res["execute_errors"] = errors
+	}
+	return res
+}
+
+// matchRule finds first rule matching request and counts its usage.
+// Rules with HTTPStatus are matched only if httpLevel is set and vice versa.
+func (s *Server) matchRule(method string, params url.Values, httpLevel bool) (int, *ErrorRule) {
+	for i := range s.fixtures.Errors {
+		rule := &s.fixtures.Errors[i]
+		if rule.Method != method || rule.Times < 0 || (rule.HTTPStatus != 0) != httpLevel {
+			continue
+		}
+		matches := true
+		for k, v := range rule.Params {
+			if params.Get(k) != v {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		switch rule.Times {
+		case 0:
+		case 1:
+			rule.Times = -1
+		default:
+			rule.Times--
+		}
+		return i, rule
+	}
+	return 0, nil
+}
+
+func (s *Server) matchError(method string, params url.Values) *vk.VkError {
+	i, rule := s.matchRule(method, params, false)
+	if rule == nil {
+		return nil
+	}
+	vkErr := &vk.VkError{Code: rule.Code, Message: rule.Code.Error()}
+	if rule.Code == vk.ErrCaptchaNeeded {
+		vkErr.CaptchaSID = strconv.Itoa(i)
+		vkErr.CaptchaImg = s.URL + "/captcha.png?sid=" + vkErr.CaptchaSID
+	}
+	return vkErr
+}
+
+func invalidParam(name string) *vk.VkError {
+	return &vk.VkError{Code: vk.ErrInvalidParams, Message: fmt.Sprintf("One of the parameters specified was missing or invalid: %s", name)}
+}
+
+func intParam(params url.Values, name string) (int, *vk.VkError) {
+	n, err := strconv.Atoi(params.Get(name))
+	if err != nil {
+		return 0, invalidParam(name)
+	}
+	return n, nil
+}
+
+func page[A any](params url.Values, items []A) (map[string]any, *vk.VkError) {
+	offset, count := 0, len(items)
+	if params.Has("offset") {
+		var err *vk.VkError
+		if offset, err = intParam(params, "offset"); err != nil {
+			return nil, err
+		}
+	}
+	if params.Has("count") {
+		var err *vk.VkError
+		if count, err = intParam(params, "count"); err != nil {
+			return nil, err
+		}
+	}
+	res := []A{}
+	if offset < len(items) {
+		end := offset + count
+		if end > len(items) {
+			end = len(items)
+		}
+		res = items[offset:end]
+	}
+	return map[string]any{
+		"count": len(items),
+		"items": res,
+	}, nil
+}
+
+func (s *Server) profiles(userIDs []vk.UserID) []vk.User {
+	res := make([]vk.User, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if user, ok := s.fixtures.Users[userID]; ok {
+			res = append(res, user)
+		} else {
+			res = append(res, vk.User{ID: userID})
+		}
+	}
+	return res
+}
+
+func (s *Server) wallGet(params url.Values) (any, *vk.VkError) {
+	ownerID, err := intParam(params, "owner_id")
+	if err != nil {
+		return nil, err
+	}
+	return page(params, s.fixtures.Walls[vk.OwnerID(ownerID)])
+}
+
+func (s *Server) wallGetByID(params url.Values) (any, *vk.VkError) {
+	res := []vk.Post{}
+	for _, id := range strings.Split(params.Get("posts"), ",") {
+		var postID vk.PostID
+		if _, err := fmt.Sscanf(id, "%d_%d", &postID.OwnerID, &postID.ID); err != nil {
+			return nil, invalidParam("posts")
+		}
+		for _, post := range s.fixtures.Walls[postID.OwnerID] {
+			if post.ID == postID.ID {
+				res = append(res, post)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) groupsGetMembers(params url.Values) (any, *vk.VkError) {
+	groupID, err := intParam(params, "group_id")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.fixtures.Groups[vk.GroupID(groupID)]; !ok {
+		return nil, invalidParam("group_id")
+	}
+	members := s.fixtures.GroupMembers[vk.GroupID(groupID)]
+	switch params.Get("filter") {
+	case "":
+	case "managers":
+		members = s.fixtures.Managers[vk.GroupID(groupID)]
+	case "friends":
+		members = intersect(members, s.fixtures.Friends[s.fixtures.Me])
+	default:
+		return nil, invalidParam("filter")
+	}
+	return page(params, s.profiles(members))
+}
+
+// intersect returns ids of as that are in bs too.
+func intersect(as, bs []vk.UserID) []vk.UserID {
+	res := []vk.UserID{}
+	for _, a := range as {
+		for _, b := range bs {
+			if a == b {
+				res = append(res, a)
+				break
+			}
+		}
+	}
+	return res
+}
+
+func (s *Server) friendsGetMutual(params url.Values) (any, *vk.VkError) {
+	sourceID, err := intParam(params, "source_uid")
+	if err != nil {
+		return nil, err
+	}
+	targetID, err := intParam(params, "target_uid")
+	if err != nil {
+		return nil, err
+	}
+	return intersect(s.fixtures.Friends[vk.UserID(sourceID)], s.fixtures.Friends[vk.UserID(targetID)]), nil
+}
+
+// pollParams parses owner and poll id of poll request.
+func pollParams(params url.Values) (vk.PollAnswerID, *vk.VkError) {
+	ownerID, err := intParam(params, "owner_id")
+	if err != nil {
+		return vk.PollAnswerID{}, err
+	}
+	pollID, err := intParam(params, "poll_id")
+	if err != nil {
+		return vk.PollAnswerID{}, err
+	}
+	return vk.PollAnswerID{OwnerID: vk.OwnerID(ownerID), PollID: uint(pollID)}, nil
+}
+
+func (s *Server) pollsGetByID(params url.Values) (any, *vk.VkError) {
+	pollID, err := pollParams(params)
+	if err != nil {
+		return nil, err
+	}
+	poll := vk.Poll{ID: pollID.PollID, OwnerID: pollID.OwnerID, Answers: []vk.PollAnswer{}}
+	for answerID, voters := range s.fixtures.PollVoters {
+		if answerID.OwnerID == pollID.OwnerID && answerID.PollID == pollID.PollID {
+			poll.Answers = append(poll.Answers, vk.PollAnswer{ID: answerID.AnswerID, Votes: uint(len(voters))})
+		}
+	}
+	if len(poll.Answers) == 0 {
+		return nil, invalidParam("poll_id")
+	}
+	sort.Slice(poll.Answers, func(i, j int) bool { return poll.Answers[i].ID < poll.Answers[j].ID })
+	return poll, nil
+}
+
+func (s *Server) pollsGetVoters(params url.Values) (any, *vk.VkError) {
+	pollID, err := pollParams(params)
+	if err != nil {
+		return nil, err
+	}
+	res := []any{}
+	for _, id := range strings.Split(params.Get("answer_ids"), ",") {
+		answerID, convErr := strconv.Atoi(id)
+		if convErr != nil {
+			return nil, invalidParam("answer_ids")
+		}
+		pollID.AnswerID = uint(answerID)
+		users, err := page(params, s.profiles(s.fixtures.PollVoters[pollID]))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, map[string]any{
+			"answer_id": answerID,
+			"users":     users,
+		})
+	}
+	return res, nil
+}
+
+func (s *Server) friendsGet(params url.Values) (any, *vk.VkError) {
+	userID, err := intParam(params, "user_id")
+	if err != nil {
+		return nil, err
+	}
+	return page(params, s.profiles(s.fixtures.Friends[vk.UserID(userID)]))
+}
+
+func (s *Server) likesGetList(params url.Values) (any, *vk.VkError) {
+	ownerID, err := intParam(params, "owner_id")
+	if err != nil {
+		return nil, err
+	}
+	itemID, err := intParam(params, "item_id")
+	if err != nil {
+		return nil, err
+	}
+	likes := s.fixtures.Likes[vk.PostID{OwnerID: vk.OwnerID(ownerID), ID: uint(itemID)}]
+	if itemType := params.Get("type"); itemType != "post" {
+		likes = s.fixtures.ItemLikes[itemType][vk.PostID{OwnerID: vk.OwnerID(ownerID), ID: uint(itemID)}]
+	}
+	if params.Get("extended") != "1" {
+		return page(params, likes)
+	}
+	items := make([]any, 0, len(likes))
+	for _, id := range likes {
+		items = append(items, s.likeItem(id))
+	}
+	return page(params, items)
+}
+
+// likeItem is user or group profile with its type, as likes.getList returns them in extended mode.
+func (s *Server) likeItem(id vk.OwnerID) any {
+	if id.IsGroup() {
+		group := s.fixtures.Groups[id.GroupID()]
+		group.ID = id.GroupID()
+		return struct {
+			Type string `json:"type"`
+			vk.Group
+		}{"group", group}
+	}
+	return struct {
+		Type string `json:"type"`
+		vk.User
+	}{"profile", s.profiles([]vk.UserID{id.UserID()})[0]}
+}
+
+type commentItem struct {
+	Comment
+	Thread struct {
+		Count int           `json:"count"`
+		Items []commentItem `json:"items,omitempty"`
+	} `json:"thread"`
+}
+
+func (s *Server) wallGetComments(params url.Values) (any, *vk.VkError) {
+	ownerID, err := intParam(params, "owner_id")
+	if err != nil {
+		return nil, err
+	}
+	postID, err := intParam(params, "post_id")
+	if err != nil {
+		return nil, err
+	}
+	comments := s.fixtures.Comments[vk.PostID{OwnerID: vk.OwnerID(ownerID), ID: uint(postID)}]
+	if params.Has("comment_id") {
+		commentID, err := intParam(params, "comment_id")
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, comment := range comments {
+			if comment.ID == uint(commentID) {
+				comments, found = comment.Replies, true
+				break
+			}
+		}
+		if !found {
+			return nil, invalidParam("comment_id")
+		}
+	}
+
+	threadItemsCount := 0
+	if params.Has("thread_items_count") && !params.Has("comment_id") {
+		if threadItemsCount, err = intParam(params, "thread_items_count"); err != nil {
+			return nil, err
+		}
+	}
+	items := make([]commentItem, 0, len(comments))
+	for _, comment := range comments {
+		item := commentItem{Comment: comment}
+		item.Thread.Count = len(comment.Replies)
+		for i := 0; i < threadItemsCount && i < len(comment.Replies); i++ {
+			item.Thread.Items = append(item.Thread.Items, commentItem{Comment: comment.Replies[i]})
+		}
+		items = append(items, item)
+	}
+	res, vkErr := page(params, items)
+	if vkErr != nil {
+		return nil, vkErr
+	}
+
+	profiles := []vk.User{}
+	groups := []vk.Group{}
+	seen := map[vk.OwnerID]bool{}
+	authors := []commentItem{}
+	for _, item := range res["items"].([]commentItem) {
+		authors = append(append(authors, item), item.Thread.Items...)
+	}
+	for _, item := range authors {
+		if seen[item.FromID] {
+			continue
+		}
+		seen[item.FromID] = true
+		if item.FromID.IsGroup() {
+			if group, ok := s.fixtures.Groups[item.FromID.GroupID()]; ok {
+				groups = append(groups, group)
+			}
+		} else {
+			profiles = append(profiles, s.profiles([]vk.UserID{item.FromID.UserID()})...)
+		}
+	}
+	res["profiles"] = profiles
+	res["groups"] = groups
+	return res, nil
+}
+
+func (s *Server) groupsGetByID(params url.Values) (any, *vk.VkError) {
+	res := []vk.Group{}
+	for _, id := range strings.Split(params.Get("group_ids"), ",") {
+		found := false
+		for _, group := range s.fixtures.Groups {
+			if group.ScreenName == id || strconv.Itoa(int(group.ID)) == id {
+				res = append(res, group)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, invalidParam("group_ids")
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) usersGet(params url.Values) (any, *vk.VkError) {
+	res := []vk.User{}
+	for _, id := range strings.Split(params.Get("user_ids"), ",") {
+		for _, user := range s.fixtures.Users {
+			if user.ScreenName == id || strconv.Itoa(int(user.ID)) == id {
+				res = append(res, user)
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) utilsResolveScreenName(params url.Values) (any, *vk.VkError) {
+	name := params.Get("screen_name")
+	for _, user := range s.fixtures.Users {
+		if user.ScreenName == name {
+			return vk.ScreenName{Type: "user", ObjectID: uint(user.ID)}, nil
+		}
+	}
+	for _, group := range s.fixtures.Groups {
+		if group.ScreenName == name {
+			return vk.ScreenName{Type: "group", ObjectID: uint(group.ID)}, nil
+		}
+	}
+	return []any{}, nil
+}